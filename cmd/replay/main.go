@@ -0,0 +1,114 @@
+// zigbee-replay : phat lai file capture (CaptureFile) cua device-zigbee tren may dev.
+//
+//	zigbee-replay -devices devices.json capture.jsonl
+//	zigbee-replay -devices devices.json -dir rx capture.pcapng
+//
+// devices.json la danh sach Device cua EdgeX (kem profile), vi du lay tu
+// core-metadata: curl http://localhost:48081/api/v1/device > devices.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/device-zigbee/driver"
+	"github.com/device-zigbee/driver/capture"
+	"github.com/edgexfoundry/go-mod-core-contracts/models"
+)
+
+func main() {
+	devicesFile := flag.String("devices", "", "file JSON chua danh sach Device cua EdgeX")
+	dir := flag.String("dir", "all", "all | rx | tx")
+	raw := flag.Bool("raw", false, "in ca payload JSON cua frame")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <capture file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var only capture.Direction
+	if *dir != "all" {
+		d, err := capture.ParseDirection(*dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		only = d
+	}
+
+	var devices []models.Device
+	if *devicesFile != "" {
+		b, err := ioutil.ReadFile(*devicesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = json.Unmarshal(b, &devices)
+		if err != nil {
+			log.Fatalf("file %s khong hop le: %v", *devicesFile, err)
+		}
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	r, err := capture.NewReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var frames, errs int
+	err = driver.Replay(r, devices, func(ev driver.ReplayEvent) {
+		if only != 0 && ev.Dir != only {
+			return
+		}
+		frames++
+		if ev.Err != nil {
+			errs++
+		}
+		printEvent(ev, *raw)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d frame, %d loi (%s)\n", frames, errs, r.Format())
+}
+
+func printEvent(ev driver.ReplayEvent, raw bool) {
+	line := fmt.Sprintf("%s %s", ev.Time.Format("2006-01-02T15:04:05.000000"), ev.Dir)
+	if ev.Cmd != "" {
+		line += " " + ev.Cmd
+	}
+	if ev.Seq != 0 {
+		line += fmt.Sprintf(" seq=%d", ev.Seq)
+	}
+	if ev.Object != "" {
+		line += " object=" + ev.Object
+	} else if ev.Address != nil {
+		line += fmt.Sprintf(" addr=0x%04X/%d", ev.Address.Address, ev.Address.Endpoint)
+	}
+	if ev.Resource != "" {
+		line += " resource=" + ev.Resource
+	}
+	if ev.Value != nil {
+		line += fmt.Sprintf(" value=%v", ev.Value)
+	}
+	if ev.Status != "" {
+		line += " status=" + ev.Status
+	}
+	if ev.Err != nil {
+		line += " ERROR: " + ev.Err.Error()
+	}
+	if raw && ev.Payload != "" {
+		line += " " + ev.Payload
+	}
+	fmt.Println(line)
+}
@@ -0,0 +1,131 @@
+// zigbee-simulator : coordinator Zigbee ao cho device-zigbee.
+//
+//	zigbee-simulator -mode tcp -listen :5000 -devices devices.json
+//	    driver dung Transport = "tcp", TCPAddress = "localhost:5000"
+//	zigbee-simulator -mode pty -devices devices.json
+//	    driver dung Transport = "serial", SerialPort = <duong dan pts duoc in ra>
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/device-zigbee/simulator"
+)
+
+func main() {
+	mode := flag.String("mode", "tcp", "tcp | pty")
+	listen := flag.String("listen", ":5000", "dia chi lang nghe khi mode = tcp")
+	devicesFile := flag.String("devices", "", "file JSON chua danh sach thiet bi ao")
+	pan := flag.Uint("pan", 0x1A62, "PAN ID cua mang")
+	report := flag.Duration("report", 0, "chu ky gui PushEvent, 0 = tat")
+	delay := flag.Duration("delay", 0, "do tre truoc khi tra loi")
+	rejoin := flag.Duration("rejoin", 0, "chu ky cho cac thiet bi da tham gia lai mang voi dia chi moi, 0 = tat")
+	flag.Parse()
+
+	var devices []simulator.Device
+	if *devicesFile != "" {
+		b, err := ioutil.ReadFile(*devicesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = json.Unmarshal(b, &devices)
+		if err != nil {
+			log.Fatalf("file %s khong hop le: %v", *devicesFile, err)
+		}
+	}
+
+	sim := simulator.New(simulator.Config{
+		Devices:        devices,
+		PAN:            uint16(*pan),
+		ReportInterval: *report,
+		ResponseDelay:  *delay,
+		Logf:           log.Printf,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		cancel()
+	}()
+	if *rejoin > 0 {
+		go rejoinLoop(ctx, sim, *rejoin)
+	}
+
+	switch *mode {
+	case "pty":
+		p, err := simulator.OpenPTY()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer p.Close()
+		log.Printf("SerialPort = %s", p.SlaveName)
+		err = sim.Serve(ctx, p)
+		if err != nil && ctx.Err() == nil {
+			log.Fatal(err)
+		}
+
+	case "tcp":
+		l, err := net.Listen("tcp", *listen)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			<-ctx.Done()
+			l.Close()
+		}()
+		log.Printf("TCPAddress = %s", l.Addr())
+		// moi luc chi phuc vu 1 driver, giong coordinator that
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Print(err)
+				time.Sleep(time.Second)
+				continue
+			}
+			log.Printf("driver ket noi tu %s", conn.RemoteAddr())
+			err = sim.Serve(ctx, conn)
+			if err != nil {
+				log.Printf("ket noi %s: %v", conn.RemoteAddr(), err)
+			}
+			conn.Close()
+		}
+
+	default:
+		log.Fatalf("mode khong hop le: %s", *mode)
+	}
+}
+
+// rejoinLoop : gia lap thiet bi mat nguon/doi parent, tham gia lai mang voi dia chi ngan moi
+func rejoinLoop(ctx context.Context, sim *simulator.Simulator, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		for _, d := range sim.Devices() {
+			if !d.Joined || d.Offline {
+				continue
+			}
+			if err := sim.Rejoin(d.MAC); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+}
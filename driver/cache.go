@@ -2,9 +2,9 @@ package driver
 
 import (
 	"strconv"
+	"strings"
 	"sync"
 
-	sdk "github.com/edgexfoundry/device-sdk-go"
 	"github.com/edgexfoundry/go-mod-core-contracts/models"
 )
 
@@ -39,10 +39,12 @@ const (
 type objectCache struct {
 	nameIDObject     map[string]string
 	idNameObject     map[string]string
-	resAttMap        map[string]AttributeInfo
-	attResMap        map[AttributeInfo]models.DeviceResource
+	resAttMap        map[resourceKey]AttributeInfo
+	attResMap        map[attributeKey]models.DeviceResource
 	addrIDObjectMap  map[ObjectAddress]string
 	idInfoObjectMap  map[string]ObjectInfo
+	macIDObjectMap   map[string][]string // dinh danh chinh cua thiet bi, cac endpoint cung MAC
+	nameProfileMap   map[string]string
 	nameMasterDevice string
 	mutex            sync.Mutex
 }
@@ -52,16 +54,32 @@ type ObjectCache interface {
 	Unlock()
 	UpdateObjectWhithoutSync(d models.Device)
 	UpdateObject(d models.Device)
+	UpdateAddress(id string, address uint16) bool
 	DeleteObject(nameObject string)
 	ConvertNameToIDObject(nameOb string) (string, bool)
 	ConvertIDToNameObject(idOb string) (string, bool)
-	ConvertAttToRes(a AttributeInfo) (models.DeviceResource, bool)
-	ConvertResToAtt(resName string) (AttributeInfo, bool)
+	ConvertAttToRes(nameOb string, a AttributeInfo) (models.DeviceResource, bool)
+	ConvertResToAtt(nameOb string, resName string) (AttributeInfo, bool)
 	ConvertAddrToIDObject(addr ObjectAddress) (string, bool)
 	ConvertIDToObjectInfo(id string) (ObjectInfo, bool)
+	ConvertMACToIDObject(mac string) (string, bool)
+	ConvertMACToIDObjects(mac string) []string
+	ConvertNameToProfile(nameOb string) (string, bool)
 	GetMasterDeviceName() string
 }
 
+// resourceKey : resource trong profile, moi profile co the dung ten resource giong nhau
+type resourceKey struct {
+	profile  string
+	resource string
+}
+
+// attributeKey : thuoc tinh trong profile
+type attributeKey struct {
+	profile string
+	AttributeInfo
+}
+
 type AttributeInfo struct {
 	ProfileID   uint16 `json:"pro"`
 	ClusterID   uint16 `json:"clu"`
@@ -160,6 +178,24 @@ func getObjectInfoFromProtocol(p map[string]models.ProtocolProperties) (ob Objec
 	return ob, true
 }
 
+// getMACFromProtocol : MAC cua thiet bi, co ca khi chua provision (chua co Address)
+func getMACFromProtocol(p map[string]models.ProtocolProperties) (string, bool) {
+	pp, ok := p[nameNetworkProtocol]
+	if !ok {
+		return "", false
+	}
+	mac, ok := pp[nameMACProperty]
+	if !ok || mac == "" {
+		return "", false
+	}
+	return normalizeMAC(mac), true
+}
+
+// normalizeMAC : MAC dang hex in hoa, khong co dau ngan cach
+func normalizeMAC(mac string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(mac))
+}
+
 func getAttributeFromMap(att map[string]string) (attInfo AttributeInfo, ok bool) {
 	profile, ok := att[nameProfileID]
 	if !ok {
@@ -223,13 +259,22 @@ func (oc *objectCache) UpdateObjectWhithoutSync(d models.Device) {
 	oldName, ok := oc.idNameObject[id]
 	if ok {
 		delete(oc.nameIDObject, oldName)
+		delete(oc.nameProfileMap, oldName)
 	}
 	oc.idNameObject[id] = d.Name
 	oc.nameIDObject[d.Name] = d.Id
+	oc.nameProfileMap[d.Name] = profile.Name
 
 	if d.Profile.Name == managerProfileNameConst {
 		oc.nameMasterDevice = d.Name
 	}
+	// dia chi cu khong con tro toi doi tuong khi Address thay doi
+	oc.deleteAddressesWhithoutSync(id)
+	delete(oc.idInfoObjectMap, id)
+	oc.deleteMACWhithoutSync(id)
+	if mac, ok := getMACFromProtocol(d.Protocols); ok && !isGroupOrScenario(d) {
+		oc.macIDObjectMap[mac] = append(oc.macIDObjectMap[mac], id)
+	}
 	obAddr, ok := getObjectAddressFromProtocol(d.Protocols)
 	if ok {
 		oc.addrIDObjectMap[obAddr] = id
@@ -239,11 +284,30 @@ func (oc *objectCache) UpdateObjectWhithoutSync(d models.Device) {
 		oc.idInfoObjectMap[id] = obInfo
 	}
 
+	oc.updateProfileWhithoutSync(profile)
+}
+
+// updateProfileWhithoutSync : thay anh xa resource - thuoc tinh cua profile
+func (oc *objectCache) updateProfileWhithoutSync(profile models.DeviceProfile) {
+	if len(profile.DeviceResources) == 0 {
+		// thiet bi khong kem noi dung profile, giu anh xa cu
+		return
+	}
+	for k := range oc.resAttMap {
+		if k.profile == profile.Name {
+			delete(oc.resAttMap, k)
+		}
+	}
+	for k := range oc.attResMap {
+		if k.profile == profile.Name {
+			delete(oc.attResMap, k)
+		}
+	}
 	for _, res := range profile.DeviceResources {
 		atInfo, ok := getAttributeFromMap(res.Attributes)
 		if ok {
-			oc.resAttMap[res.Name] = atInfo
-			oc.attResMap[atInfo] = res
+			oc.resAttMap[resourceKey{profile.Name, res.Name}] = atInfo
+			oc.attResMap[attributeKey{profile.Name, atInfo}] = res
 		}
 	}
 }
@@ -255,6 +319,23 @@ func (oc *objectCache) UpdateObject(d models.Device) {
 	oc.UpdateObjectWhithoutSync(d)
 }
 
+// UpdateAddress : doi dia chi ngan cua doi tuong id (thiet bi tham gia lai mang),
+// chi sua chi muc dia chi, giu nguyen anh xa resource - thuoc tinh cua profile
+func (oc *objectCache) UpdateAddress(id string, address uint16) bool {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+
+	info, ok := oc.idInfoObjectMap[id]
+	if !ok {
+		return false
+	}
+	oc.deleteAddressesWhithoutSync(id)
+	info.Address = address
+	oc.idInfoObjectMap[id] = info
+	oc.addrIDObjectMap[info.ObjectAddress] = id
+	return true
+}
+
 func (oc *objectCache) DeleteObject(nameObject string) {
 	oc.mutex.Lock()
 	defer oc.mutex.Unlock()
@@ -263,10 +344,42 @@ func (oc *objectCache) DeleteObject(nameObject string) {
 	if ok {
 		delete(oc.nameIDObject, nameObject)
 		delete(oc.idNameObject, id)
-		obInfo, ok := oc.idInfoObjectMap[id]
-		if ok {
-			delete(oc.addrIDObjectMap, obInfo.ObjectAddress)
-			delete(oc.idInfoObjectMap, id)
+		delete(oc.nameProfileMap, nameObject)
+		oc.deleteAddressesWhithoutSync(id)
+		delete(oc.idInfoObjectMap, id)
+		oc.deleteMACWhithoutSync(id)
+	}
+}
+
+// deleteMACWhithoutSync : xoa doi tuong id khoi chi muc MAC
+func (oc *objectCache) deleteMACWhithoutSync(id string) {
+	for mac, ids := range oc.macIDObjectMap {
+		for i, v := range ids {
+			if v != id {
+				continue
+			}
+			ids = append(ids[:i:i], ids[i+1:]...)
+			if len(ids) == 0 {
+				delete(oc.macIDObjectMap, mac)
+			} else {
+				oc.macIDObjectMap[mac] = ids
+			}
+			break
+		}
+	}
+}
+
+// isGroupOrScenario : group/scenario khong phai thiet bi vat ly, khong co MAC rieng
+func isGroupOrScenario(d models.Device) bool {
+	t := labelsType(d.Labels).getType()
+	return t == GROUPTYPE || t == SCENARIOTYPE
+}
+
+// deleteAddressesWhithoutSync : xoa cac dia chi tro toi doi tuong id
+func (oc *objectCache) deleteAddressesWhithoutSync(id string) {
+	for addr, addrID := range oc.addrIDObjectMap {
+		if addrID == id {
+			delete(oc.addrIDObjectMap, addr)
 		}
 	}
 }
@@ -287,19 +400,29 @@ func (oc *objectCache) ConvertIDToNameObject(idOb string) (string, bool) {
 	return r, ok
 }
 
-func (oc *objectCache) ConvertAttToRes(a AttributeInfo) (models.DeviceResource, bool) {
+// ConvertAttToRes : resource cua thuoc tinh a trong profile cua doi tuong nameOb
+func (oc *objectCache) ConvertAttToRes(nameOb string, a AttributeInfo) (models.DeviceResource, bool) {
 	oc.mutex.Lock()
 	defer oc.mutex.Unlock()
 
-	r, ok := oc.attResMap[a]
+	profile, ok := oc.nameProfileMap[nameOb]
+	if !ok {
+		return models.DeviceResource{}, false
+	}
+	r, ok := oc.attResMap[attributeKey{profile, a}]
 	return r, ok
 }
 
-func (oc *objectCache) ConvertResToAtt(resName string) (AttributeInfo, bool) {
+// ConvertResToAtt : thuoc tinh cua resource resName trong profile cua doi tuong nameOb
+func (oc *objectCache) ConvertResToAtt(nameOb string, resName string) (AttributeInfo, bool) {
 	oc.mutex.Lock()
 	defer oc.mutex.Unlock()
 
-	r, ok := oc.resAttMap[resName]
+	profile, ok := oc.nameProfileMap[nameOb]
+	if !ok {
+		return AttributeInfo{}, false
+	}
+	r, ok := oc.resAttMap[resourceKey{profile, resName}]
 	return r, ok
 }
 
@@ -319,6 +442,34 @@ func (oc *objectCache) ConvertIDToObjectInfo(id string) (ObjectInfo, bool) {
 	return r, ok
 }
 
+// ConvertMACToIDObject : doi tuong dau tien co MAC
+func (oc *objectCache) ConvertMACToIDObject(mac string) (string, bool) {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+
+	ids := oc.macIDObjectMap[normalizeMAC(mac)]
+	if len(ids) == 0 {
+		return "", false
+	}
+	return ids[0], true
+}
+
+// ConvertMACToIDObjects : moi doi tuong (endpoint) cua thiet bi co MAC
+func (oc *objectCache) ConvertMACToIDObjects(mac string) []string {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+
+	return append([]string(nil), oc.macIDObjectMap[normalizeMAC(mac)]...)
+}
+
+func (oc *objectCache) ConvertNameToProfile(nameOb string) (string, bool) {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+
+	r, ok := oc.nameProfileMap[nameOb]
+	return r, ok
+}
+
 func (oc *objectCache) GetMasterDeviceName() string {
 	oc.mutex.Lock()
 	defer oc.mutex.Unlock()
@@ -330,32 +481,39 @@ func (oc *objectCache) GetMasterDeviceName() string {
 
 func initCache() {
 	initOnce.Do(func() {
-		svc := sdk.RunningService()
-		ds := svc.Devices()
-
-		defaultSize := len(ds) * 2
-		idNameObject := make(map[string]string, defaultSize)
-		nameIDObject := make(map[string]string, defaultSize)
-		resAttMap := make(map[string]AttributeInfo, len(ds))
-		attResMap := make(map[AttributeInfo]models.DeviceResource, len(ds))
-		addrIDObjectMap := make(map[ObjectAddress]string, defaultSize)
-		idInfoObjectMap := make(map[string]ObjectInfo, defaultSize)
-
-		oc = &objectCache{
-			nameIDObject:     nameIDObject,
-			idNameObject:     idNameObject,
-			resAttMap:        resAttMap,
-			attResMap:        attResMap,
-			addrIDObjectMap:  addrIDObjectMap,
-			idInfoObjectMap:  idInfoObjectMap,
-			nameMasterDevice: "",
-		}
-		for _, d := range ds {
-			oc.UpdateObject(d)
-		}
+		svc := runningService()
+		oc = newObjectCache(svc.Devices())
 	})
 }
 
+func newObjectCache(ds []models.Device) *objectCache {
+	defaultSize := len(ds) * 2
+	idNameObject := make(map[string]string, defaultSize)
+	nameIDObject := make(map[string]string, defaultSize)
+	resAttMap := make(map[resourceKey]AttributeInfo, len(ds))
+	attResMap := make(map[attributeKey]models.DeviceResource, len(ds))
+	addrIDObjectMap := make(map[ObjectAddress]string, defaultSize)
+	idInfoObjectMap := make(map[string]ObjectInfo, defaultSize)
+	macIDObjectMap := make(map[string][]string, defaultSize)
+	nameProfileMap := make(map[string]string, defaultSize)
+
+	c := &objectCache{
+		nameIDObject:     nameIDObject,
+		idNameObject:     idNameObject,
+		resAttMap:        resAttMap,
+		attResMap:        attResMap,
+		addrIDObjectMap:  addrIDObjectMap,
+		idInfoObjectMap:  idInfoObjectMap,
+		macIDObjectMap:   macIDObjectMap,
+		nameProfileMap:   nameProfileMap,
+		nameMasterDevice: "",
+	}
+	for _, d := range ds {
+		c.UpdateObject(d)
+	}
+	return c
+}
+
 func Cache() ObjectCache {
 	if oc == nil {
 		initCache()
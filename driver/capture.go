@@ -0,0 +1,98 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/device-zigbee/driver/capture"
+)
+
+// ten cac khoa cau hinh ghi lai frame UART trong muc [Driver]
+const (
+	nameCaptureFileConfig     = "CaptureFile"     // duong dan file capture, rong = tat
+	nameCaptureFormatConfig   = "CaptureFormat"   // "jsonl" | "pcapng"
+	nameCaptureMaxSizeConfig  = "CaptureMaxSize"  // KB, vuot qua thi xoay vong file
+	nameCaptureMaxFilesConfig = "CaptureMaxFiles" // so file cu giu lai
+)
+
+const (
+	defaultCaptureMaxSize  = 10 * 1024 // KB
+	defaultCaptureMaxFiles = 5
+)
+
+var (
+	captureMutex  sync.Mutex
+	captureWriter *capture.Writer
+)
+
+// initCapture : doc cau hinh capture tu muc [Driver] cua configuration.toml va mo file
+func initCapture(config map[string]string) error {
+	path, ok := configValue(config, nameCaptureFileConfig)
+	if !ok {
+		return nil
+	}
+	cfg := capture.Config{
+		Path:     path,
+		Format:   capture.JSONL,
+		MaxSize:  defaultCaptureMaxSize * 1024,
+		MaxFiles: defaultCaptureMaxFiles,
+	}
+	if v, ok := configValue(config, nameCaptureFormatConfig); ok {
+		f, err := capture.ParseFormat(v)
+		if err != nil {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameCaptureFormatConfig, v)
+		}
+		cfg.Format = f
+	}
+	if v, ok := configValue(config, nameCaptureMaxSizeConfig); ok {
+		kb, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameCaptureMaxSizeConfig, v)
+		}
+		cfg.MaxSize = int64(kb) * 1024
+	}
+	if v, ok := configValue(config, nameCaptureMaxFilesConfig); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameCaptureMaxFilesConfig, v)
+		}
+		cfg.MaxFiles = n
+	}
+
+	w, err := capture.NewWriter(cfg)
+	if err != nil {
+		return err
+	}
+	captureMutex.Lock()
+	captureWriter = w
+	captureMutex.Unlock()
+	driver.Logger.Info(fmt.Sprintf("Capture frame UART vao %s (%s)", cfg.Path, cfg.Format))
+	return nil
+}
+
+// captureFrame : ghi lai frame da gui/nhan neu capture dang bat
+func captureFrame(dir capture.Direction, data []byte) {
+	captureMutex.Lock()
+	w := captureWriter
+	captureMutex.Unlock()
+	if w == nil {
+		return
+	}
+	err := w.Write(capture.Record{Time: time.Now(), Dir: dir, Data: data})
+	if err != nil {
+		driver.Logger.Error(fmt.Sprintf("Capture: loi ghi file: %v", err))
+	}
+}
+
+// closeCapture : dong file capture khi Stop
+func closeCapture() {
+	captureMutex.Lock()
+	w := captureWriter
+	captureWriter = nil
+	captureMutex.Unlock()
+	if w != nil {
+		w.Close()
+	}
+}
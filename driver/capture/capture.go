@@ -0,0 +1,189 @@
+// Package capture : ghi lai cac frame UART gui/nhan (kem thoi gian, chieu) ra file
+// de phan tich hoac phat lai (cmd/replay).
+//
+// Hai dinh dang:
+//
+//	jsonl  : moi dong 1 Record dang JSON, doc bang grep/jq
+//	pcapng : mo bang Wireshark, link type LinkType (LINKTYPE_USER0),
+//	         chieu frame nam trong option epb_flags (inbound = RX, outbound = TX)
+//
+// File dang ghi dat ten theo Config.Path; khi vuot MaxSize duoc doi ten thanh
+// Path.1, Path.1 thanh Path.2, ... giu toi da MaxFiles file cu.
+package capture
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Direction : chieu cua frame so voi driver
+type Direction uint8
+
+const (
+	// TX : driver -> coordinator
+	TX Direction = iota + 1
+	// RX : coordinator -> driver
+	RX
+)
+
+func (d Direction) String() string {
+	switch d {
+	case TX:
+		return "tx"
+	case RX:
+		return "rx"
+	}
+	return "unknown"
+}
+
+// ParseDirection : nguoc lai cua String
+func ParseDirection(s string) (Direction, error) {
+	switch s {
+	case "tx":
+		return TX, nil
+	case "rx":
+		return RX, nil
+	}
+	return 0, fmt.Errorf("capture: chieu khong hop le: %q", s)
+}
+
+// Format : dinh dang file capture
+type Format string
+
+const (
+	JSONL  Format = "jsonl"
+	PCAPNG Format = "pcapng"
+)
+
+// ParseFormat : "jsonl" | "pcapng"
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case JSONL, PCAPNG:
+		return f, nil
+	}
+	return "", fmt.Errorf("capture: dinh dang khong hop le: %q (jsonl, pcapng)", s)
+}
+
+// Record : 1 frame da gui/nhan, Data la cac byte tren duong truyen (ca Header va CRC)
+type Record struct {
+	Time time.Time
+	Dir  Direction
+	Data []byte
+}
+
+// Config : cau hinh Writer
+type Config struct {
+	Path     string
+	Format   Format
+	MaxSize  int64 // byte, 0 = khong xoay vong
+	MaxFiles int   // so file cu giu lai
+}
+
+// encoder : ghi Record theo 1 dinh dang, start duoc goi o dau moi file
+type encoder interface {
+	start(w io.Writer) (int64, error)
+	encode(w io.Writer, rec Record) (int64, error)
+}
+
+// Writer : ghi Record ra file, an toan khi goi tu nhieu goroutine
+type Writer struct {
+	mutex sync.Mutex
+	cfg   Config
+	enc   encoder
+	file  *os.File
+	size  int64
+}
+
+// NewWriter : mo (tao moi) file capture
+func NewWriter(cfg Config) (*Writer, error) {
+	w := &Writer{cfg: cfg}
+	switch cfg.Format {
+	case JSONL:
+		w.enc = jsonlEncoder{}
+	case PCAPNG:
+		w.enc = pcapngEncoder{}
+	default:
+		return nil, fmt.Errorf("capture: dinh dang khong hop le: %q", cfg.Format)
+	}
+	err := w.open()
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write : ghi 1 Record, xoay vong file neu vuot MaxSize
+func (w *Writer) Write(rec Record) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if w.cfg.MaxSize > 0 && w.size >= w.cfg.MaxSize {
+		err := w.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := w.enc.encode(w.file, rec)
+	w.size += n
+	return err
+}
+
+// Close : dong file dang ghi
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	n, err := w.enc.start(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = n
+	return nil
+}
+
+// rotate : Path.(n-1) -> Path.n, ..., Path -> Path.1 roi mo file moi
+func (w *Writer) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return err
+	}
+	if w.cfg.MaxFiles <= 0 {
+		os.Remove(w.cfg.Path)
+		return w.open()
+	}
+	os.Remove(rotatedName(w.cfg.Path, w.cfg.MaxFiles))
+	for i := w.cfg.MaxFiles - 1; i >= 1; i-- {
+		os.Rename(rotatedName(w.cfg.Path, i), rotatedName(w.cfg.Path, i+1))
+	}
+	err = os.Rename(w.cfg.Path, rotatedName(w.cfg.Path, 1))
+	if err != nil {
+		return err
+	}
+	return w.open()
+}
+
+func rotatedName(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
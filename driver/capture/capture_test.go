@@ -0,0 +1,155 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testRecords : n Record xen ke TX/RX, do dai khac nhau (ca frame ngan hon Header+Cmd+CRC).
+// Thoi gian tron micro giay nhu do phan giai cua pcapng
+func testRecords(n int) []Record {
+	start := time.Date(2024, time.March, 1, 8, 0, 0, 0, time.UTC)
+	records := make([]Record, n)
+	for i := range records {
+		payload := []byte(fmt.Sprintf(`{"seq":%d,"addr":%d}`, i, 0x4f21+i))
+		data := append([]byte{0xAA, 0, byte(len(payload) + 1), byte(i)}, payload...)
+		data = append(data, byte(i*7))
+		if i%5 == 4 {
+			data = data[:i%3]
+		}
+		dir := TX
+		if i%2 == 1 {
+			dir = RX
+		}
+		records[i] = Record{
+			Time: start.Add(time.Duration(i) * 1500 * time.Microsecond),
+			Dir:  dir,
+			Data: data,
+		}
+	}
+	return records
+}
+
+// readAll : Record trong cac file capture tu cu nhat (Path.n) toi moi nhat (Path)
+func readAll(t *testing.T, path string, format Format) []Record {
+	var names []string
+	for i := 1; ; i++ {
+		if _, err := os.Stat(rotatedName(path, i)); err != nil {
+			break
+		}
+		names = append([]string{rotatedName(path, i)}, names...)
+	}
+	names = append(names, path)
+
+	var records []Record
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(f)
+		if err != nil {
+			f.Close()
+			t.Fatalf("NewReader(%s): %v", name, err)
+		}
+		if r.Format() != format {
+			t.Fatalf("%s: format = %s, want %s", name, r.Format(), format)
+		}
+		for {
+			rec, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: record %d: %v", name, len(records), err)
+			}
+			records = append(records, rec)
+		}
+		f.Close()
+	}
+	return records
+}
+
+func sameRecords(t *testing.T, got []Record, want []Record) {
+	if len(got) != len(want) {
+		t.Fatalf("read %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || got[i].Dir != want[i].Dir || !bytes.Equal(got[i].Data, want[i].Data) {
+			t.Fatalf("record %d = {%s %s %x}, want {%s %s %x}", i,
+				got[i].Time, got[i].Dir, got[i].Data, want[i].Time, want[i].Dir, want[i].Data)
+		}
+	}
+}
+
+func writeRecords(t *testing.T, cfg Config, records []Record) {
+	w, err := NewWriter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, rec := range records {
+		err = w.Write(rec)
+		if err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(records[0]); err != os.ErrClosed {
+		t.Fatalf("Write after Close: %v", err)
+	}
+}
+
+// TestRoundTrip : ghi qua nhieu lan xoay vong roi doc lai dung cac Record da ghi
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{JSONL, PCAPNG} {
+		t.Run(string(format), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "capture")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "uart."+string(format))
+			records := testRecords(40)
+
+			writeRecords(t, Config{Path: path, Format: format, MaxSize: 512, MaxFiles: 100}, records)
+			if _, err := os.Stat(rotatedName(path, 2)); err != nil {
+				t.Fatalf("capture was not rotated: %v", err)
+			}
+			sameRecords(t, readAll(t, path, format), records)
+		})
+	}
+}
+
+// TestRotateMaxFiles : chi giu MaxFiles file cu, cac Record con lai la phan cuoi da ghi
+func TestRotateMaxFiles(t *testing.T) {
+	for _, format := range []Format{JSONL, PCAPNG} {
+		t.Run(string(format), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "capture")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "uart."+string(format))
+			records := testRecords(40)
+
+			writeRecords(t, Config{Path: path, Format: format, MaxSize: 512, MaxFiles: 2}, records)
+			if _, err := os.Stat(rotatedName(path, 3)); !os.IsNotExist(err) {
+				t.Fatalf("%s kept: %v", rotatedName(path, 3), err)
+			}
+			got := readAll(t, path, format)
+			if len(got) == 0 || len(got) >= len(records) {
+				t.Fatalf("read %d of %d records after rotation", len(got), len(records))
+			}
+			sameRecords(t, got, records[len(records)-len(got):])
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// jsonlRecord : 1 dong cua file jsonl. Payload (JSON cua frame) chi de doc, Data moi la du lieu goc
+type jsonlRecord struct {
+	Time    string `json:"ts"`
+	Dir     string `json:"dir"`
+	Cmd     *byte  `json:"cmd,omitempty"`
+	Payload string `json:"payload,omitempty"`
+	Data    string `json:"data"` // hex
+}
+
+type jsonlEncoder struct{}
+
+func (jsonlEncoder) start(w io.Writer) (int64, error) {
+	return 0, nil
+}
+
+func (jsonlEncoder) encode(w io.Writer, rec Record) (int64, error) {
+	r := jsonlRecord{
+		Time: rec.Time.UTC().Format(time.RFC3339Nano),
+		Dir:  rec.Dir.String(),
+		Data: hex.EncodeToString(rec.Data),
+	}
+	// Header(1) Lenght(2) Cmd(1) Payload CRC(1)
+	if len(rec.Data) >= 5 {
+		cmd := rec.Data[3]
+		r.Cmd = &cmd
+		r.Payload = string(rec.Data[4 : len(rec.Data)-1])
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return 0, err
+	}
+	b = append(b, '\n')
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+type jsonlDecoder struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newJSONLDecoder(r io.Reader) *jsonlDecoder {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &jsonlDecoder{s: s}
+}
+
+func (d *jsonlDecoder) next() (Record, error) {
+	for d.s.Scan() {
+		d.line++
+		line := d.s.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var r jsonlRecord
+		err := json.Unmarshal(line, &r)
+		if err != nil {
+			return Record{}, fmt.Errorf("capture: dong %d: %v", d.line, err)
+		}
+		var rec Record
+		rec.Time, err = time.Parse(time.RFC3339Nano, r.Time)
+		if err != nil {
+			return Record{}, fmt.Errorf("capture: dong %d: %v", d.line, err)
+		}
+		rec.Dir, err = ParseDirection(r.Dir)
+		if err != nil {
+			return Record{}, fmt.Errorf("capture: dong %d: %v", d.line, err)
+		}
+		rec.Data, err = hex.DecodeString(r.Data)
+		if err != nil {
+			return Record{}, fmt.Errorf("capture: dong %d: %v", d.line, err)
+		}
+		return rec, nil
+	}
+	if err := d.s.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
@@ -0,0 +1,198 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// LinkType : LINKTYPE_USER0, Wireshark hien thi du lieu tho (co the gan dissector rieng)
+const LinkType = 147
+
+const (
+	pcapngSHB = 0x0A0D0D0A // Section Header Block
+	pcapngIDB = 0x00000001 // Interface Description Block
+	pcapngEPB = 0x00000006 // Enhanced Packet Block
+
+	pcapngByteOrderMagic = 0x1A2B3C4D
+
+	pcapngOptEnd     = 0
+	pcapngOptEPBFlag = 2
+
+	// epb_flags bit 0-1: chieu
+	pcapngInbound  = 1
+	pcapngOutbound = 2
+
+	// maxBlockLength : bo qua file hong thay vi cap phat qua lon
+	maxBlockLength = 16 * 1024 * 1024
+)
+
+// pcapngMagic : 4 byte dau cua file pcapng, dung de nhan dang dinh dang
+var pcapngMagic = []byte{0x0A, 0x0D, 0x0D, 0x0A}
+
+// pcapngEncoder : ghi little endian, timestamp don vi micro giay (if_tsresol mac dinh)
+type pcapngEncoder struct{}
+
+func (pcapngEncoder) start(w io.Writer) (int64, error) {
+	var b []byte
+	// SHB: type, length, magic, major 1, minor 0, section length -1, length
+	b = appendUint32(b, pcapngSHB)
+	b = appendUint32(b, 28)
+	b = appendUint32(b, pcapngByteOrderMagic)
+	b = appendUint16(b, 1)
+	b = appendUint16(b, 0)
+	b = appendUint32(b, 0xFFFFFFFF)
+	b = appendUint32(b, 0xFFFFFFFF)
+	b = appendUint32(b, 28)
+	// IDB: type, length, link type, reserved, snaplen 0 (khong gioi han), length
+	b = appendUint32(b, pcapngIDB)
+	b = appendUint32(b, 20)
+	b = appendUint16(b, LinkType)
+	b = appendUint16(b, 0)
+	b = appendUint32(b, 0)
+	b = appendUint32(b, 20)
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (pcapngEncoder) encode(w io.Writer, rec Record) (int64, error) {
+	padded := (len(rec.Data) + 3) &^ 3
+	// 28 byte truong co dinh + du lieu + option epb_flags (8) + opt_endofopt (4) + length (4)
+	length := 28 + padded + 8 + 4 + 4
+	flags := uint32(pcapngInbound)
+	if rec.Dir == TX {
+		flags = pcapngOutbound
+	}
+	ts := uint64(rec.Time.UnixNano() / int64(time.Microsecond))
+
+	b := make([]byte, 0, length)
+	b = appendUint32(b, pcapngEPB)
+	b = appendUint32(b, uint32(length))
+	b = appendUint32(b, 0) // interface id
+	b = appendUint32(b, uint32(ts>>32))
+	b = appendUint32(b, uint32(ts))
+	b = appendUint32(b, uint32(len(rec.Data)))
+	b = appendUint32(b, uint32(len(rec.Data)))
+	b = append(b, rec.Data...)
+	b = append(b, make([]byte, padded-len(rec.Data))...)
+	b = appendUint16(b, pcapngOptEPBFlag)
+	b = appendUint16(b, 4)
+	b = appendUint32(b, flags)
+	b = appendUint16(b, pcapngOptEnd)
+	b = appendUint16(b, 0)
+	b = appendUint32(b, uint32(length))
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// pcapngDecoder : doc EPB cua file pcapng, bo qua cac block khac.
+// Chi doc duoc timestamp micro giay (file do Writer ghi hoac if_tsresol mac dinh)
+type pcapngDecoder struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+}
+
+func newPCAPNGDecoder(r *bufio.Reader) *pcapngDecoder {
+	return &pcapngDecoder{r: r, order: binary.LittleEndian}
+}
+
+func (d *pcapngDecoder) next() (Record, error) {
+	for {
+		var head [8]byte
+		_, err := io.ReadFull(d.r, head[:])
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return Record{}, fmt.Errorf("capture: pcapng bi cat giua block")
+			}
+			return Record{}, err
+		}
+		if binary.LittleEndian.Uint32(head[:4]) == pcapngSHB {
+			// thu tu byte cua section nam ngay sau length
+			var magic [4]byte
+			_, err = io.ReadFull(d.r, magic[:])
+			if err != nil {
+				return Record{}, fmt.Errorf("capture: pcapng bi cat giua block")
+			}
+			switch {
+			case binary.LittleEndian.Uint32(magic[:]) == pcapngByteOrderMagic:
+				d.order = binary.LittleEndian
+			case binary.BigEndian.Uint32(magic[:]) == pcapngByteOrderMagic:
+				d.order = binary.BigEndian
+			default:
+				return Record{}, fmt.Errorf("capture: pcapng sai byte-order magic")
+			}
+			_, err = d.body(d.order.Uint32(head[4:]), 12)
+			if err != nil {
+				return Record{}, err
+			}
+			continue
+		}
+
+		blockType := d.order.Uint32(head[:4])
+		body, err := d.body(d.order.Uint32(head[4:]), 8)
+		if err != nil {
+			return Record{}, err
+		}
+		if blockType != pcapngEPB {
+			continue
+		}
+		return d.epb(body)
+	}
+}
+
+// body : doc phan con lai cua block (da doc read byte), bo truong length o cuoi
+func (d *pcapngDecoder) body(length uint32, read int) ([]byte, error) {
+	if length < uint32(read)+4 || length%4 != 0 || length > maxBlockLength {
+		return nil, fmt.Errorf("capture: pcapng do dai block khong hop le: %d", length)
+	}
+	b := make([]byte, int(length)-read)
+	_, err := io.ReadFull(d.r, b)
+	if err != nil {
+		return nil, fmt.Errorf("capture: pcapng bi cat giua block")
+	}
+	return b[:len(b)-4], nil
+}
+
+func (d *pcapngDecoder) epb(b []byte) (Record, error) {
+	if len(b) < 20 {
+		return Record{}, fmt.Errorf("capture: pcapng EPB qua ngan")
+	}
+	ts := uint64(d.order.Uint32(b[4:]))<<32 | uint64(d.order.Uint32(b[8:]))
+	capLen := int(d.order.Uint32(b[12:]))
+	padded := (capLen + 3) &^ 3
+	if capLen < 0 || 20+padded > len(b) {
+		return Record{}, fmt.Errorf("capture: pcapng EPB sai do dai du lieu")
+	}
+	rec := Record{
+		Time: time.Unix(0, int64(ts)*int64(time.Microsecond)),
+		Dir:  RX,
+		Data: append([]byte(nil), b[20:20+capLen]...),
+	}
+	opts := b[20+padded:]
+	for len(opts) >= 4 {
+		code := d.order.Uint16(opts)
+		n := int(d.order.Uint16(opts[2:]))
+		if code == pcapngOptEnd || 4+n > len(opts) {
+			break
+		}
+		if code == pcapngOptEPBFlag && n == 4 && d.order.Uint32(opts[4:])&3 == pcapngOutbound {
+			rec.Dir = TX
+		}
+		next := 4 + (n+3)&^3
+		if next > len(opts) {
+			break
+		}
+		opts = opts[next:]
+	}
+	return rec, nil
+}
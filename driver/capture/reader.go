@@ -0,0 +1,40 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+type recordDecoder interface {
+	next() (Record, error)
+}
+
+// Reader : doc lai file capture, tu nhan dang jsonl/pcapng
+type Reader struct {
+	dec    recordDecoder
+	format Format
+}
+
+// NewReader : dinh dang duoc xac dinh tu cac byte dau cua r
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(pcapngMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(head, pcapngMagic) {
+		return &Reader{dec: newPCAPNGDecoder(br), format: PCAPNG}, nil
+	}
+	return &Reader{dec: newJSONLDecoder(br), format: JSONL}, nil
+}
+
+// Format : dinh dang cua file dang doc
+func (r *Reader) Format() Format {
+	return r.format
+}
+
+// Next : Record tiep theo, io.EOF khi het file
+func (r *Reader) Next() (Record, error) {
+	return r.dec.next()
+}
@@ -0,0 +1,255 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/device-zigbee/driver/packet"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/models"
+)
+
+// ten cac khoa cau hinh discovery trong muc [Driver]
+const (
+	nameDiscoveryProfileConfig  = "DiscoveryProfile"  // profile gan cho thiet bi moi, rong = chi liet ke
+	nameDiscoveryScanTimeConfig = "DiscoveryScanTime" // thoi gian mo permit-join mac dinh (s)
+)
+
+const (
+	defaultScanTime        = 60
+	maxScanTime            = 127 // ScanTime la int8
+	discoveredDevicePrefix = "Zigbee-"
+)
+
+// DiscoveredDevice : thiet bi nhan duoc device-announce trong thoi gian scan
+type DiscoveredDevice struct {
+	ObjectInfo
+	NameDevice  string `json:"name,omitempty"`
+	Description string `json:"desc,omitempty"`
+}
+
+// contentScanType : Body cua lenh Scan gui toi manager device
+type contentScanType struct {
+	ScanTime int8   `json:"time,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+}
+
+type discoverySession struct {
+	mutex sync.Mutex
+	found map[string]DiscoveredDevice // theo MAC
+	order []string
+}
+
+var (
+	discoveryMutex  sync.Mutex
+	activeDiscovery *discoverySession
+)
+
+// deviceAnnounceGoroutine : xu ly frame device-announce tu coordinator: tham gia lai mang
+// cua thiet bi da biet, hoac thiet bi moi trong thoi gian scan
+func deviceAnnounceGoroutine(data ResponseCommonFrame) {
+	if data.MAC == "" {
+		return
+	}
+	// thiet bi da co trong cache tham gia lai mang: cap nhat dia chi ngan
+	updateShortAddress(data.MAC, data.Address)
+
+	discoveryMutex.Lock()
+	session := activeDiscovery
+	discoveryMutex.Unlock()
+	if session == nil {
+		return
+	}
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if _, ok := session.found[data.MAC]; !ok {
+		session.order = append(session.order, data.MAC)
+	}
+	session.found[data.MAC] = DiscoveredDevice{
+		ObjectInfo:  data.ObjectInfo,
+		NameDevice:  data.NameDevice,
+		Description: data.Description,
+	}
+	driver.Logger.Info(fmt.Sprintf("Discovery: thiet bi MAC=%s Address=%d tham gia mang", data.MAC, data.Address))
+}
+
+// startScan : yeu cau coordinator mo permit-join trong scanTime giay, bat dau thu thap device-announce
+func (d *Driver) startScan(ctx context.Context, scanTime int8) (*discoverySession, error) {
+	if scanTime <= 0 || scanTime > maxScanTime {
+		return nil, fmt.Errorf("zigbee: scan time %d out of range (1-%d)", scanTime, maxScanTime)
+	}
+
+	discoveryMutex.Lock()
+	if activeDiscovery != nil {
+		discoveryMutex.Unlock()
+		return nil, fmt.Errorf("zigbee: a device scan is already running")
+	}
+	session := &discoverySession{found: make(map[string]DiscoveredDevice)}
+	activeDiscovery = session
+	discoveryMutex.Unlock()
+
+	err := d.sendScanRequest(ctx, scanTime)
+	if err != nil {
+		stopScan(session)
+		return nil, err
+	}
+	return session, nil
+}
+
+func (d *Driver) sendScanRequest(ctx context.Context, scanTime int8) error {
+	_, err := sendAndAwait(ctx, request{
+		cmd: ScanCmdConst,
+		key: packet.Repo().GetRepoNameByCMD(ScanCmdConst),
+		frame: func(seq uint8) interface{} {
+			return ScanDeviceFrame{
+				Seq:      seq,
+				ScanTime: scanTime,
+			}
+		},
+		dest:    "scan",
+		timeout: currentTimeouts().response,
+	})
+	return err
+}
+
+// stopScan : ket thuc phien scan, tra ve cac thiet bi chua co trong cache
+func stopScan(session *discoverySession) []DiscoveredDevice {
+	discoveryMutex.Lock()
+	if activeDiscovery == session {
+		activeDiscovery = nil
+	}
+	discoveryMutex.Unlock()
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	result := make([]DiscoveredDevice, 0, len(session.order))
+	for _, mac := range session.order {
+		if _, known := Cache().ConvertMACToIDObject(mac); known {
+			continue
+		}
+		result = append(result, session.found[mac])
+	}
+	return result
+}
+
+// scanDevices : scan trong scanTime giay (hoac den khi ctx ket thuc), tra ve thiet bi moi
+func (d *Driver) scanDevices(ctx context.Context, scanTime int8) ([]DiscoveredDevice, error) {
+	session, err := d.startScan(ctx, scanTime)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-time.After(time.Duration(scanTime) * time.Second):
+	case <-ctx.Done():
+	}
+	return stopScan(session), nil
+}
+
+// registerDiscoveredDevices : them thiet bi moi vao EdgeX voi profile, AddDevice se provision chung
+func registerDiscoveredDevices(devices []DiscoveredDevice, profile string) []string {
+	service := runningService()
+	added := make([]string, 0, len(devices))
+	for _, dev := range devices {
+		name := dev.NameDevice
+		if name == "" {
+			name = discoveredDevicePrefix + dev.MAC
+		}
+		device := models.Device{
+			Name:            name,
+			DescribedObject: models.DescribedObject{Description: dev.Description},
+			AdminState:      models.Unlocked,
+			OperatingState:  models.Enabled,
+			Labels:          []string{DEVICETYPE, UNINITIALIZIED},
+			Profile:         models.DeviceProfile{Name: profile},
+			Protocols: map[string]models.ProtocolProperties{
+				nameNetworkProtocol: {
+					nameMACProperty: dev.MAC,
+					namePANProperty: strconv.FormatUint(uint64(dev.PAN), 10),
+				},
+			},
+		}
+		_, err := service.AddDevice(device)
+		if err != nil {
+			driver.Logger.Error(fmt.Sprintf("Discovery: khong them duoc thiet bi %s: %v", name, err))
+			continue
+		}
+		added = append(added, name)
+	}
+	return added
+}
+
+// handleScanRequest : lenh Scan cua manager device, tra ve khi coordinator chap nhan,
+// viec thu thap va them thiet bi chay nen
+func (d *Driver) handleScanRequest(ctx context.Context, body string) error {
+	content := contentScanType{}
+	if body != "" {
+		err := json.Unmarshal([]byte(body), &content)
+		if err != nil {
+			return fmt.Errorf("zigbee: invalid Scan body: %v", err)
+		}
+	}
+	config := runningService().DriverConfigs()
+	if content.ScanTime == 0 {
+		content.ScanTime = configScanTime(config)
+	}
+	if content.Profile == "" {
+		content.Profile = config[nameDiscoveryProfileConfig]
+	}
+
+	session, err := d.startScan(ctx, content.ScanTime)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		select {
+		case <-time.After(time.Duration(content.ScanTime) * time.Second):
+		case <-d.context().Done():
+		}
+		devices := stopScan(session)
+		driver.Logger.Info(fmt.Sprintf("Discovery: tim thay %d thiet bi moi", len(devices)))
+		if content.Profile == "" {
+			for _, dev := range devices {
+				driver.Logger.Info(fmt.Sprintf("Discovery: %+v", dev))
+			}
+			return
+		}
+		added := registerDiscoveredDevices(devices, content.Profile)
+		driver.Logger.Info(fmt.Sprintf("Discovery: da them %d thiet bi: %v", len(added), added))
+	}()
+	return nil
+}
+
+func configScanTime(config map[string]string) int8 {
+	if v, ok := configValue(config, nameDiscoveryScanTimeConfig); ok {
+		n, err := strconv.ParseInt(v, 10, 8)
+		if err == nil && n > 0 {
+			return int8(n)
+		}
+		driver.Logger.Warn(fmt.Sprintf("Cau hinh %s khong hop le: %s, dung %d", nameDiscoveryScanTimeConfig, v, defaultScanTime))
+	}
+	return defaultScanTime
+}
+
+// Discover : trien khai sdkModel.ProtocolDiscovery, scan voi thoi gian mac dinh,
+// them thiet bi moi neu co DiscoveryProfile va tra ve danh sach thiet bi moi
+func (d *Driver) Discover() (*interface{}, error) {
+	ctx, cancel := context.WithCancel(d.context())
+	defer cancel()
+
+	config := runningService().DriverConfigs()
+	devices, err := d.scanDevices(ctx, configScanTime(config))
+	if err != nil {
+		return nil, err
+	}
+	if profile := config[nameDiscoveryProfileConfig]; profile != "" {
+		registerDiscoveredDevices(devices, profile)
+	}
+	var result interface{} = devices
+	return &result, nil
+}
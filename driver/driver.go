@@ -8,16 +8,16 @@ package driver
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/device-zigbee/driver/packet"
 
-	sdk "github.com/edgexfoundry/device-sdk-go"
 	sdkModel "github.com/edgexfoundry/device-sdk-go/pkg/models"
 	"github.com/edgexfoundry/go-mod-core-contracts/clients/logger"
 	"github.com/edgexfoundry/go-mod-core-contracts/models"
@@ -31,6 +31,8 @@ var driver *Driver
 type Driver struct {
 	Logger  logger.LoggingClient
 	AsyncCh chan<- *sdkModel.AsyncValues
+	ctx     context.Context // bi huy khi Stop(), cac yeu cau dang cho ket thuc ngay
+	cancel  context.CancelFunc
 }
 
 // NewProtocolDriver : khoi tao driver, duoc goi trong ham main()
@@ -47,16 +49,97 @@ func NewProtocolDriver() sdkModel.ProtocolDriver {
 func (d *Driver) Initialize(lc logger.LoggingClient, asyncCh chan<- *sdkModel.AsyncValues) error {
 	d.Logger = lc
 	d.AsyncCh = asyncCh
+	d.ctx, d.cancel = context.WithCancel(context.Background())
 	Cache()
 	packet.Repo()
-	driver := sdk.DriverConfigs()
-	port, ok := driver["SerialPort"]
-	if !ok {
-		return fmt.Errorf("Khong chi dinh SerialPort")
+	service := runningService()
+	config := service.DriverConfigs()
+	err := initTimeouts(config)
+	if err != nil {
+		return err
+	}
+	err = initTxWindow(config)
+	if err != nil {
+		return err
+	}
+	err = initRemoval(config)
+	if err != nil {
+		return err
+	}
+	err = initCapture(config)
+	if err != nil {
+		return err
+	}
+	err = initState(config)
+	if err != nil {
+		return err
+	}
+	metadataURL, err := service.MetadataURL()
+	if err != nil {
+		return err
+	}
+	err = initReconcile(config, metadataURL)
+	if err != nil {
+		return err
+	}
+	err = initStringFormat(config)
+	if err != nil {
+		return err
+	}
+	t, err := newTransportFromConfig(config)
+	if err != nil {
+		return err
+	}
+	err = TransceiverInit(t)
+	if err != nil {
+		return err
+	}
+	err = initProvisioning(config)
+	if err != nil {
+		return err
+	}
+	err = service.AddRoute(linkStatsRoute, linkStatsHandler, http.MethodGet)
+	if err != nil {
+		return err
+	}
+	err = service.AddRoute(metricsRoute, metricsHandler, http.MethodGet)
+	if err != nil {
+		return err
+	}
+	err = service.AddRoute(stateTablesRoute, stateTablesHandler, http.MethodGet)
+	if err != nil {
+		return err
+	}
+	d.resumeProvisioning()
+	d.resumeRemovals()
+	if interval := currentReconcileInterval(); interval > 0 {
+		go d.reconcileLoop(interval)
 	}
-	err := TransceiverInit(port)
 
-	return err
+	return nil
+}
+
+// context : context cua driver, ket thuc khi Stop
+func (d *Driver) context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// requestContext : context cho 1 yeu cau tu SDK, gioi han boi RequestTimeout (neu co)
+func (d *Driver) requestContext() (context.Context, context.CancelFunc) {
+	parent := d.context()
+	if t := currentTimeouts().request; t > 0 {
+		return context.WithTimeout(parent, t)
+	}
+	return context.WithCancel(parent)
+}
+
+// backgroundContext : context cho viec driver tu chay o nen (provision, thu xoa lai),
+// khong bi gioi han boi RequestTimeout, moi lan gui van co thoi gian cho rieng
+func (d *Driver) backgroundContext() (context.Context, context.CancelFunc) {
+	return context.WithCancel(d.context())
 }
 
 // command of Master: {
@@ -70,6 +153,8 @@ const (
 	managerSubcribe     = "Subscribe"
 	mangerSchedule      = "Schedule"
 	managerRemoveItself = "RemoveItself"
+	managerScan         = "Scan"      // Body: {"time": <s>, "profile": <ten profile>}, khong can ManagerObjectName
+	managerReconcile    = "Reconcile" // doi chieu cache voi metadata ngay, khong can ManagerObjectName
 	managerPutMethod    = "PUT"
 	managerDeleteMethod = "DELETE"
 )
@@ -125,10 +210,28 @@ func (d *Driver) HandleReadCommands(deviceName string, protocols map[string]mode
 	var responses = make([]*sdkModel.CommandValue, len(reqs))
 	var err error
 
+	ctx, cancel := d.requestContext()
+	defer cancel()
+	timeout := responseTimeoutOf(protocols)
+
 	for i, req := range reqs {
-		res, err := d.handleReadCommandRequest(deviceName, req)
+		start := time.Now()
+		if res, ok := cachedReading(deviceName, req); ok {
+			metrics.observeCommand(deviceName, metricOpReadCached, start, nil)
+			responses[i] = res
+			continue
+		}
+		// chi lenh can gui qua mang moi that bai ngay khi mat ket noi
+		if !isLocalResource(req.DeviceResourceName) && !LinkIsUp() {
+			logCommandError(metricOpRead, deviceName, req.DeviceResourceName, ErrLinkDown)
+			return responses, ErrLinkDown
+		}
+		res, err := d.handleReadCommandRequest(ctx, deviceName, req, timeout)
+		if !isLocalResource(req.DeviceResourceName) {
+			metrics.observeCommand(deviceName, metricOpRead, start, err)
+		}
 		if err != nil {
-			driver.Logger.Info(fmt.Sprintf("Handle read commands failed: %v", err))
+			logCommandError(metricOpRead, deviceName, req.DeviceResourceName, err)
 			return responses, err
 		}
 		responses[i] = res
@@ -137,76 +240,81 @@ func (d *Driver) HandleReadCommands(deviceName string, protocols map[string]mode
 	return responses, err
 }
 
-func (d *Driver) handleReadCommandRequest(objectName string, req sdkModel.CommandRequest) (*sdkModel.CommandValue, error) {
+// isLocalResource : resource do driver tu tra loi, khong gui toi thiet bi
+func isLocalResource(name string) bool {
+	return name == linkStatsResource || name == provisionStatusResource
+}
+
+// isLocalManagerCommand : lenh cua manager khong gui toi coordinator
+func isLocalManagerCommand(params []*sdkModel.CommandValue) bool {
+	if len(params) != 4 {
+		return false
+	}
+	cmName, err := params[1].StringValue()
+	return err == nil && cmName == managerReconcile
+}
+
+func (d *Driver) handleReadCommandRequest(ctx context.Context, objectName string, req sdkModel.CommandRequest, timeout time.Duration) (*sdkModel.CommandValue, error) {
 	var result = &sdkModel.CommandValue{}
 	var err error
 
+	if req.DeviceResourceName == linkStatsResource && objectName == Cache().GetMasterDeviceName() {
+		stats, err := linkStatsJSON()
+		if err != nil {
+			return result, err
+		}
+		return newResult(req, stats)
+	}
+	if req.DeviceResourceName == provisionStatusResource {
+		status, err := provisionStatusValue(objectName)
+		if err != nil {
+			return result, err
+		}
+		return newResult(req, status)
+	}
+
 	idObject, ok := Cache().ConvertNameToIDObject(objectName)
 	if !ok {
-		return result, fmt.Errorf("Khong ton tai doi tuong")
+		return result, ErrObjectUnknown
 	}
 
 	objectInfo, ok := Cache().ConvertIDToObjectInfo(idObject)
 	if !ok {
-		return result, fmt.Errorf("Khong co thong tin dia chi doi tuong")
+		return result, ErrObjectNoAddress
 	}
 	commandID := int8(CommandIDRead)
-	attInfo, ok := Cache().ConvertResToAtt(req.DeviceResourceName)
+	attInfo, ok := Cache().ConvertResToAtt(objectName, req.DeviceResourceName)
 	if !ok {
-		return result, fmt.Errorf("Khong the chuyen doi Resource sang Attribute Zigbee")
-	}
-
-	cmFrame := CommandFrame{
-		ObjectAddress: objectInfo.ObjectAddress,
-		CommandID:     commandID,
-		AttributeInfo: attInfo,
-	}
-	// crate TX_frame
-	contentRepo := ContentRepo{
-		Cmd:     CommandCmdConst,
-		Content: cmFrame,
-	}
-
-	_, err = SendUartPacket(contentRepo, 5000)
+		return result, ErrAttributeUnmapped
+	}
+
+	response, err := sendAndAwait(ctx, request{
+		cmd: CommandCmdConst,
+		key: packet.Repo().GetRepoNameByID(idObject),
+		frame: func(seq uint8) interface{} {
+			return CommandFrame{
+				ObjectAddress: objectInfo.ObjectAddress,
+				Seq:           seq,
+				CommandID:     commandID,
+				AttributeInfo: attInfo,
+			}
+		},
+		dest:    destinationOfAddress(objectInfo.ObjectAddress),
+		timeout: timeout,
+	})
 	if err != nil {
-		driver.Logger.Error(err.Error())
 		return result, err
 	}
-	driver.Logger.Info(fmt.Sprintf("Send command: %+v", contentRepo))
-
-	nameRepo := packet.Repo().GetRepoNameByID(idObject)
-	responseRaw, ok := packet.Repo().GetFromRepoAfterResetWithTime(nameRepo, 100, 50)
-	if !ok {
-		return result, fmt.Errorf("Loi nhan phan hoi")
-	}
 
-	driver.Logger.Info(fmt.Sprintf("Parse command response: %+v", responseRaw))
-	respByte, _ := json.Marshal(responseRaw)
-	var responseRepo packet.ContentRepoStruct
-	err = json.Unmarshal(respByte, &responseRepo)
+	reading, err := readingOf(req, attInfo, response.Value)
 	if err != nil {
-		return result, fmt.Errorf("Loi phan tich phan hoi")
-	}
-
-	respByte, _ = json.Marshal(responseRepo.Packet)
-	var response ResponseCommonFrame
-
-	err = json.Unmarshal(respByte, &response)
-	if err != nil {
-		return result, fmt.Errorf("Loi phan tich phan hoi")
-	}
-
-	statusResponse := response.StatusResponse
-
-	if statusResponse != 0 {
-		return result, fmt.Errorf("Lenh gui toi Device Zigbee khong thanh cong")
+		return result, err
 	}
-
-	reading := response.Value
 	result, err = newResult(req, reading)
 	if err != nil {
 		return result, err
 	}
+	saveValue(objectName, req.DeviceResourceName, response.Value, result.Origin)
 	driver.Logger.Info(fmt.Sprintf("Get command finished: %+v", result))
 
 	return result, err
@@ -225,6 +333,8 @@ func getBytes(data interface{}) ([]byte, error) {
 		return buf.Bytes(), nil
 	case string:
 		return []byte(v), nil
+	case []byte:
+		return v, nil
 	case bool:
 		r := make([]byte, 1)
 		if v == true {
@@ -251,11 +361,20 @@ func getBytes(data interface{}) ([]byte, error) {
 	}
 }
 
+// appendValue : chep gia tri vao o 8 byte bat dau tu offset, chuoi dai hon thi noi tiep
+func appendValue(result []byte, offset int, value []byte) []byte {
+	if len(value) > len(result)-offset {
+		return append(result[:offset], value...)
+	}
+	copy(result[offset:], value)
+	return result
+}
+
 func convertScheduleStructZigbeeToBinary(from ScheduleStructZigbee) []byte {
 	result := make([]byte, (2 + 1 + 1 + 18 + 4 + 2 + 2 + 2 + 1 + 8))
 	var bValue []byte
 
-	bValue, _ = getBytes(from.Value)
+	bValue, _ = attributeBytes(from.AttributeInfo, from.Value)
 	dValue, _ := getBytes(from.DateHoMuSe)
 
 	result[0] = byte(from.Address >> 8)
@@ -274,8 +393,7 @@ func convertScheduleStructZigbeeToBinary(from ScheduleStructZigbee) []byte {
 	result[30] = byte(from.AttributeID >> 8)
 	result[31] = byte(from.AttributeID & 0x00FF)
 	result[32] = byte(from.ValueType)
-	copy(result[33:], bValue)
-	return result
+	return appendValue(result, 33, bValue)
 }
 
 func convertSubscribeStructZigbeeToBinary(from SubscribeStructZigbee, attNil bool) []byte {
@@ -290,7 +408,7 @@ func convertSubscribeStructZigbeeToBinary(from SubscribeStructZigbee, attNil boo
 	result := make([]byte, (2 + 1 + 1 + 2 + 2 + 2 + 1 + 8))
 	var bValue []byte
 
-	bValue, _ = getBytes(from.Value)
+	bValue, _ = attributeBytes(from.AttributeInfo, from.Value)
 
 	result[0] = byte(from.Address >> 8)
 	result[1] = byte(from.Address & 0x00FF)
@@ -303,8 +421,7 @@ func convertSubscribeStructZigbeeToBinary(from SubscribeStructZigbee, attNil boo
 	result[8] = byte(from.AttributeID >> 8)
 	result[9] = byte(from.AttributeID & 0x00FF)
 	result[10] = byte(from.ValueType)
-	copy(result[11:], bValue)
-	return result
+	return appendValue(result, 11, bValue)
 }
 
 func convertByteToUint8(in []byte) []uint16 {
@@ -315,29 +432,37 @@ func convertByteToUint8(in []byte) []uint16 {
 	return out
 }
 
-func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*sdkModel.CommandValue) error {
+func (d *Driver) handleMasterRequest(ctx context.Context, reqs []sdkModel.CommandRequest, params []*sdkModel.CommandValue) error {
 	if len(reqs) != 4 {
-		driver.Logger.Info("Yeu cau khong hop le")
-		return fmt.Errorf("Yeu cau khong hop le")
+		return ErrInvalidRequest
+	}
+	cmName, err := params[1].StringValue()
+	if err != nil {
+		return err
+	}
+	if cmName == managerScan {
+		body, err := params[3].StringValue()
+		if err != nil {
+			return err
+		}
+		return d.handleScanRequest(ctx, body)
+	}
+	if cmName == managerReconcile {
+		_, err := d.reconcile()
+		return err
 	}
+
 	objectName, err := params[0].StringValue()
 	if err != nil {
 		return err
 	}
 	objectID, ok := Cache().ConvertNameToIDObject(objectName)
 	if !ok {
-		driver.Logger.Info("Khong ton tai doi tuong")
-		return fmt.Errorf("Khong ton tai doi tuong")
+		return ErrObjectUnknown
 	}
 	objectInfo, ok := Cache().ConvertIDToObjectInfo(objectID)
 	if !ok {
-		driver.Logger.Info("Khong co thong tin dia chi doi tuong")
-		return fmt.Errorf("Khong co thong tin dia chi doi tuong")
-	}
-
-	cmName, err := params[1].StringValue()
-	if err != nil {
-		return err
+		return ErrObjectNoAddress
 	}
 
 	method, err := params[2].StringValue()
@@ -350,8 +475,7 @@ func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*s
 	} else if method == managerDeleteMethod {
 		commandID = CommandIDDelete
 	} else {
-		driver.Logger.Info("Khong ho tro Method:" + method)
-		return fmt.Errorf("Khong ho tro Method:" + method)
+		return fmt.Errorf("zigbee: unsupported manager method %q", method)
 	}
 
 	body, err := params[3].StringValue()
@@ -359,15 +483,17 @@ func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*s
 		return err
 	}
 
-	service := sdk.RunningService()
+	service := runningService()
 
 	// deviceObject, ok := service.DeviceResource(deviceName, cmd, "get")
 	var cmFrame CommandFrame
+	var entryKey string // muc trong bang cua coordinator, xem saveCoordinatorEntry
 
 	switch cmName {
 	case managerSubcribe:
 		var content contentElementType
 		json.Unmarshal([]byte(body), &content)
+		entryKey = tableKey(cmName, objectName, content.OwnerID, content.ElementID)
 
 		object, err := service.GetDeviceByName(objectName)
 		if err != nil {
@@ -376,8 +502,7 @@ func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*s
 
 		addrInfoOwer, ok := Cache().ConvertIDToObjectInfo(content.OwnerID)
 		if !ok {
-			driver.Logger.Info("Khong ton tai doi tuong:" + content.OwnerID)
-			return fmt.Errorf("Khong ton tai doi tuong:" + content.OwnerID)
+			return UnknownOwnerError{content.OwnerID}
 		}
 
 		var newreqs []sdkModel.CommandRequest
@@ -387,8 +512,7 @@ func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*s
 
 		ownerName, ok := Cache().ConvertIDToNameObject(content.OwnerID)
 		if !ok {
-			driver.Logger.Info("Khong ton tai doi tuong")
-			return fmt.Errorf("Khong ton tai doi tuong")
+			return UnknownOwnerError{content.OwnerID}
 		}
 		objectOwner, err := service.GetDeviceByName(ownerName)
 		if err != nil {
@@ -397,25 +521,19 @@ func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*s
 
 		if labelsType(objectOwner.Labels).getType() == SCENARIOTYPE {
 			attvlNil = false
-			fmt.Println("driver 399: vao scenario")
 			newreqs, newparams, err = execWriteCmd(d, object, content.Command, content.Body)
 			if err != nil {
 				driver.Logger.Info(fmt.Sprintf("chuyen doi lenh loi: %v", err))
 				return err
 			}
-			v, e := newparams[0].Int8Value()
-			fmt.Printf("driver 401: Int8Value=%d-%v\n", v, e)
 			// hien tai chi ho tro 1 command - value
-			att, ok := Cache().ConvertResToAtt(newreqs[0].DeviceResourceName)
+			att, ok := Cache().ConvertResToAtt(object.Name, newreqs[0].DeviceResourceName)
 			if !ok {
-				driver.Logger.Info("Khong tim thay Attribute Zigbee cho:" + objectName)
-				return fmt.Errorf("Khong tim thay Attribute Zigbee cho:" + objectName)
+				return ErrAttributeUnmapped
 			}
 			attValue, err := newCommandValue(newreqs[0].Type, newparams[0])
-			fmt.Printf("driver 409:%+v\n", attValue)
 			if err != nil {
-				driver.Logger.Info("Doc gia tri Command Value loi")
-				return fmt.Errorf("Doc gia tri Command Value loi")
+				return err
 			}
 			attvl = AttributeValue{
 				AttributeInfo: att,
@@ -445,6 +563,7 @@ func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*s
 	case mangerSchedule:
 		var content contentScheduleType
 		json.Unmarshal([]byte(body), &content)
+		entryKey = tableKey(cmName, objectName, content.OwnerID, content.ScheduleName)
 
 		object, err := service.GetDeviceByName(objectName)
 		if err != nil {
@@ -453,8 +572,7 @@ func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*s
 
 		addrInfoOwer, ok := Cache().ConvertIDToObjectInfo(content.OwnerID)
 		if !ok {
-			driver.Logger.Info("Khong ton tai doi tuong:" + content.OwnerID)
-			return fmt.Errorf("Khong ton tai doi tuong:" + content.OwnerID)
+			return UnknownOwnerError{content.OwnerID}
 		}
 
 		var newreqs []sdkModel.CommandRequest
@@ -468,15 +586,13 @@ func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*s
 		}
 
 		// hien tai chi ho tro 1 command - value
-		att, ok := Cache().ConvertResToAtt(newreqs[0].DeviceResourceName)
+		att, ok := Cache().ConvertResToAtt(object.Name, newreqs[0].DeviceResourceName)
 		if !ok {
-			driver.Logger.Info("Khong tim thay Attribute Zigbee cho:" + objectName)
-			return fmt.Errorf("Khong tim thay Attribute Zigbee cho:" + objectName)
+			return ErrAttributeUnmapped
 		}
 		attValue, err := newCommandValue(newreqs[0].Type, newparams[0])
 		if err != nil {
-			driver.Logger.Info("Doc gia tri Command Value loi")
-			return fmt.Errorf("Doc gia tri Command Value loi")
+			return err
 		}
 		// TODO: xac dinh loai gia tri cho Value
 		// Hien tai co dinh type(Value) = uint8_t
@@ -513,51 +629,26 @@ func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*s
 			Value:         AttributeValue{},
 		}
 	default:
-		return fmt.Errorf("Khong ho tro yeu cau:" + cmName)
-	}
-	// crate TX_frame
-	contentRepo := ContentRepo{
-		Cmd:     CommandCmdConst,
-		Content: cmFrame,
-	}
-	nameRepo := packet.Repo().GetRepoNameByID(objectID)
-
-	driver.Logger.Info(fmt.Sprintf("gui vao Repo: %s : contentRepo= %+v", nameRepo, contentRepo))
-
-	_, err = SendUartPacket(contentRepo, 5000)
+		return fmt.Errorf("zigbee: unsupported manager command %q", cmName)
+	}
+	timeout := currentTimeouts().response
+	if object, err := service.GetDeviceByName(objectName); err == nil {
+		timeout = responseTimeoutOf(object.Protocols)
+	}
+	_, err = sendAndAwait(ctx, request{
+		cmd: CommandCmdConst,
+		key: packet.Repo().GetRepoNameByID(objectID),
+		frame: func(seq uint8) interface{} {
+			cmFrame.Seq = seq
+			return cmFrame
+		},
+		dest:    destinationOfAddress(objectInfo.ObjectAddress),
+		timeout: timeout,
+	})
 	if err != nil {
-		driver.Logger.Error(err.Error())
 		return err
 	}
-	driver.Logger.Info(fmt.Sprintf("Send command: %+v", contentRepo))
-
-	responseRaw, ok := packet.Repo().GetFromRepoAfterResetWithTime(nameRepo, 100, 50)
-	if !ok {
-		driver.Logger.Info("Loi khong nhan duoc phan hoi")
-		return fmt.Errorf("Loi khong nhan duoc phan hoi")
-	}
-
-	driver.Logger.Info(fmt.Sprintf("Parse command response: %+v", responseRaw))
-	respByte, _ := json.Marshal(responseRaw)
-	var responseRepo packet.ContentRepoStruct
-	err = json.Unmarshal(respByte, &responseRepo)
-	if err != nil {
-		return fmt.Errorf("Loi phan tich phan hoi")
-	}
-
-	respByte, _ = json.Marshal(responseRepo.Packet)
-	var response ResponseCommonFrame
-
-	err = json.Unmarshal(respByte, &response)
-	if err != nil {
-		return fmt.Errorf("Loi phan tich phan hoi")
-	}
-
-	statusResponse := response.StatusResponse
-
-	if statusResponse != 0 {
-		return fmt.Errorf("Lenh gui toi Device Zigbee khong thanh cong")
-	}
+	saveCoordinatorEntry(cmName, method, objectName, entryKey, body)
 
 	driver.Logger.Info(fmt.Sprintf("Put command finished"))
 	return nil
@@ -569,14 +660,32 @@ func (d *Driver) handleMasterRequest(reqs []sdkModel.CommandRequest, params []*s
 // command.
 func (d *Driver) HandleWriteCommands(objectName string, protocols map[string]models.ProtocolProperties, reqs []sdkModel.CommandRequest, params []*sdkModel.CommandValue) error {
 	var err error
-	if Cache().GetMasterDeviceName() == objectName {
-		return d.handleMasterRequest(reqs, params)
+	isMaster := Cache().GetMasterDeviceName() == objectName
+	// lenh chi lam viec voi core-metadata (Reconcile) van chay khi mat ket noi toi coordinator
+	if !(isMaster && isLocalManagerCommand(params)) && !LinkIsUp() {
+		logCommandError(metricOpWrite, objectName, "", ErrLinkDown)
+		return ErrLinkDown
+	}
+	ctx, cancel := d.requestContext()
+	defer cancel()
+	if isMaster {
+		err = d.handleMasterRequest(ctx, reqs, params)
+		if err != nil {
+			logCommandError(metricOpWrite, objectName, "", err)
+		}
+		return err
 	}
 
+	timeout := responseTimeoutOf(protocols)
 	for i, req := range reqs {
-		err = d.handleWriteCommandRequest(objectName, req, params[i])
+		start := time.Now()
+		err = d.handleWriteCommandRequest(ctx, objectName, req, params[i], timeout)
+		metrics.observeCommand(objectName, metricOpWrite, start, err)
+		// ke ca khi loi, thiet bi co the da nhan gia tri moi
+		forgetValue(objectName, req.DeviceResourceName)
+		forgetMemberValues(objectName, req.DeviceResourceName)
 		if err != nil {
-			driver.Logger.Info(fmt.Sprintf("Handle write commands failed: %v", err))
+			logCommandError(metricOpWrite, objectName, req.DeviceResourceName, err)
 			return err
 		}
 	}
@@ -584,74 +693,50 @@ func (d *Driver) HandleWriteCommands(objectName string, protocols map[string]mod
 	return err
 }
 
-func (d *Driver) handleWriteCommandRequest(objectName string, req sdkModel.CommandRequest, param *sdkModel.CommandValue) error {
+func (d *Driver) handleWriteCommandRequest(ctx context.Context, objectName string, req sdkModel.CommandRequest, param *sdkModel.CommandValue, timeout time.Duration) error {
 	var err error
 
 	idObject, ok := Cache().ConvertNameToIDObject(objectName)
 	if !ok {
-		return fmt.Errorf("Khong ton tai doi tuong")
+		return ErrObjectUnknown
 	}
 
 	objectInfo, ok := Cache().ConvertIDToObjectInfo(idObject)
 	if !ok {
-		return fmt.Errorf("Khong co thong tin dia chi doi tuong")
+		return ErrObjectNoAddress
 	}
 	commandID := int8(CommandIDWrite)
-	attInfo, ok := Cache().ConvertResToAtt(req.DeviceResourceName)
+	attInfo, ok := Cache().ConvertResToAtt(objectName, req.DeviceResourceName)
 	if !ok {
-		return fmt.Errorf("Khong the chuyen doi Resource sang Attribute Zigbee")
+		return ErrAttributeUnmapped
 	}
 
 	commandValue, err := newCommandValue(req.Type, param)
 	if err != nil {
 		return err
 	}
-
-	cmFrame := CommandFrame{
-		ObjectAddress: objectInfo.ObjectAddress,
-		CommandID:     commandID,
-		AttributeInfo: attInfo,
-		Value:         commandValue,
-	}
-	// crate TX_frame
-	contentRepo := ContentRepo{
-		Cmd:     CommandCmdConst,
-		Content: cmFrame,
-	}
-
-	_, err = SendUartPacket(contentRepo, 5000)
+	commandValue, err = attributeValueOf(attInfo, commandValue)
 	if err != nil {
-		driver.Logger.Error(err.Error())
 		return err
 	}
-	// driver.Logger.Info(fmt.Sprintf("Send command: %+v", contentRepo))
-
-	nameRepo := packet.Repo().GetRepoNameByID(idObject)
-	responseRaw, ok := packet.Repo().GetFromRepoAfterResetWithTime(nameRepo, 100, 50)
-	if !ok {
-		return fmt.Errorf("Loi nhan phan hoi")
-	}
-
-	driver.Logger.Info(fmt.Sprintf("Parse command response: %+v", responseRaw))
-	respByte, _ := json.Marshal(responseRaw)
-	var responseRepo packet.ContentRepoStruct
-	err = json.Unmarshal(respByte, &responseRepo)
-	if err != nil {
-		return fmt.Errorf("Loi phan tich phan hoi")
-	}
-
-	respByte, _ = json.Marshal(responseRepo.Packet)
-	var response ResponseCommonFrame
 
-	err = json.Unmarshal(respByte, &response)
+	_, err = sendAndAwait(ctx, request{
+		cmd: CommandCmdConst,
+		key: packet.Repo().GetRepoNameByID(idObject),
+		frame: func(seq uint8) interface{} {
+			return CommandFrame{
+				ObjectAddress: objectInfo.ObjectAddress,
+				Seq:           seq,
+				CommandID:     commandID,
+				AttributeInfo: attInfo,
+				Value:         commandValue,
+			}
+		},
+		dest:    destinationOfAddress(objectInfo.ObjectAddress),
+		timeout: timeout,
+	})
 	if err != nil {
-		return fmt.Errorf("Loi phan tich phan hoi")
-	}
-
-	statusResponse := response.StatusResponse
-
-	if statusResponse != 0 {
-		return fmt.Errorf("Lenh gui toi Device Zigbee khong thanh cong")
+		return err
 	}
 
 	driver.Logger.Info(fmt.Sprintf("Put command finished"))
@@ -683,6 +768,12 @@ func newResult(req sdkModel.CommandRequest, reading interface{}) (*sdkModel.Comm
 			return nil, fmt.Errorf(castError, req.DeviceResourceName, err)
 		}
 		result = sdkModel.NewStringValue(req.DeviceResourceName, resTime, val)
+	case sdkModel.Binary:
+		val, err := toBinary(reading)
+		if err != nil {
+			return nil, fmt.Errorf(castError, req.DeviceResourceName, err)
+		}
+		result, err = sdkModel.NewBinaryValue(req.DeviceResourceName, resTime, val)
 	case sdkModel.Uint8:
 		val, err := cast.ToUint8E(reading)
 		if err != nil {
@@ -758,6 +849,8 @@ func newCommandValue(valueType sdkModel.ValueType, param *sdkModel.CommandValue)
 		commandValue, err = param.BoolValue()
 	case sdkModel.String:
 		commandValue, err = param.StringValue()
+	case sdkModel.Binary:
+		commandValue, err = param.BinaryValue()
 	case sdkModel.Uint8:
 		commandValue, err = param.Uint8Value()
 	case sdkModel.Uint16:
@@ -796,7 +889,12 @@ func newCommandValue(valueType sdkModel.ValueType, param *sdkModel.CommandValue)
 // readings (if supported).
 func (d *Driver) Stop(force bool) error {
 	d.Logger.Warn("Driver's Stop function didn't implement")
+	if d.cancel != nil {
+		d.cancel()
+	}
 	TransceiverClose()
+	closeCapture()
+	closeState()
 	return nil
 }
 
@@ -806,23 +904,6 @@ const (
 	UNINITIALIZIED = "uninitializied"
 )
 
-func createProvisionObjectContentRepo(frame ProvisonFrame) (result ContentRepo, repoName string) {
-	result.Cmd = AddObjectCmdConst
-	result.Content = frame
-
-	repoName = packet.Repo().GetRepoNameByCMD(AddObjectCmdConst)
-	return
-}
-
-func createDeleteObjectContentRepo(addr ObjectAddress) (result ContentRepo, repoName string) {
-	result.Cmd = DeleteObjectCmdConst
-	result.Content = DeleteObjectFrame{
-		ObjectAddress: addr,
-	}
-	repoName = packet.Repo().GetRepoNameByCMD(DeleteObjectCmdConst)
-	return
-}
-
 const (
 	// PROTOCOLSNETWORKNAME :
 	PROTOCOLSNETWORKNAME = "Network"
@@ -832,7 +913,7 @@ const (
 // when a new Device associated with this Device Service is added
 func (d *Driver) AddDevice(deviceName string, protocols map[string]models.ProtocolProperties, adminState models.AdminState) error {
 	d.Logger.Debug(fmt.Sprintf("Device %s is added", deviceName))
-	service := sdk.RunningService()
+	service := runningService()
 	device, err := service.GetDeviceByName(deviceName)
 	if err != nil {
 		return err
@@ -840,94 +921,13 @@ func (d *Driver) AddDevice(deviceName string, protocols map[string]models.Protoc
 
 	Cache().UpdateObject(device)
 
-	var mac string
-	var pan uint16
-	if len(device.Protocols) > 0 {
-		pp, ok := device.Protocols[PROTOCOLSNETWORKNAME]
-		if !ok {
-			driver.Logger.Error(fmt.Sprintf("Khong co thong tin vat ly cua thiet bi"))
-			return fmt.Errorf("Khong co thong tin vat ly cua thiet bi")
-		}
-		strMac, _ := pp["MAC"]
-		strPan, _ := pp["PAN"]
-		// umac, _ := strconv.ParseUint(strMac, 10, 64)
-		upan, _ := strconv.ParseUint(strPan, 10, 16)
-		mac = strMac
-		pan = uint16(upan)
-	}
-	if labelsType(device.Labels).isInitializied() == false {
-		// provision
-		var frame ProvisonFrame
-		if labelsType(device.Labels).getType() == DEVICETYPE {
-
-			frame = ProvisonFrame{
-				AddressEUI64: AddressEUI64{
-					MAC: mac,
-					PAN: pan,
-				},
-				NameDevice: deviceName,
-			}
-		} else {
-			frame = ProvisonFrame{
-				AddressEUI64: AddressEUI64{
-					MAC: "00000000",
-					PAN: pan,
-				},
-				NameDevice: deviceName,
-			}
-		}
-
-		repo, repoName := createProvisionObjectContentRepo(frame)
-		_, err := SendUartPacket(repo, 8000) // gui trong 4s
-		if err != nil {
-			driver.Logger.Error(err.Error())
-			return err
-		}
-		driver.Logger.Info(fmt.Sprintf("Send request add object: %+v", repo))
-
-		responseRaw, ok := packet.Repo().GetFromRepoAfterResetWithTime(repoName, 20000, 1)
-		if !ok {
-			return fmt.Errorf("Loi nhan phan hoi")
-		}
-
-		driver.Logger.Info(fmt.Sprintf("Parse command response: %+v", responseRaw))
-		respByte, _ := json.Marshal(responseRaw)
-		var responseRepo packet.ContentRepoStruct
-		err = json.Unmarshal(respByte, &responseRepo)
-		if err != nil {
-			return fmt.Errorf("Loi phan tich phan hoi")
-		}
-
-		respByte, _ = json.Marshal(responseRepo.Packet)
-		var response ResponseCommonFrame
-
-		err = json.Unmarshal(respByte, &response)
-		if err != nil {
-			return fmt.Errorf("Loi phan tich phan hoi")
-		}
-		if response.StatusResponse != 0x00 {
-			driver.Logger.Info(fmt.Sprintf("Status response: %+v", response.StatusResponse))
-			return fmt.Errorf("Yeu cau thuc hien khong thanh cong")
-		}
-		objectInfo := response.ObjectInfo
-		nw, ok := device.Protocols[nameNetworkProtocol]
-		if !ok {
-			nw = make(map[string]string)
-		}
-		nw[nameMACProperty] = objectInfo.MAC
-		nw[namePANProperty] = strconv.FormatUint(uint64(objectInfo.PAN), 10)
-		nw[nameAddressProperty] = strconv.FormatUint(uint64(objectInfo.Address), 10)
-		nw[nameEndpointProperty] = strconv.FormatUint(uint64(objectInfo.Endpoint), 10)
-		nw[nameTypeProperty] = strconv.FormatUint(uint64(objectInfo.Type), 10)
-		device.Protocols[nameNetworkProtocol] = nw
-
-		if response.Description != "" {
-			device.Description = response.Description
-		}
-		labelsType(device.Labels).setInitializied()
-		service.UpdateDevice(device)
+	if device.Profile.Name == managerProfileNameConst {
+		return nil
+	}
+	if provisionStatusOf(device).needsWork() {
+		// provision chay nen, trang thai xem qua resource ProvisionStatus
+		provisioning().start(d, device)
 	}
-
 	return nil
 }
 
@@ -935,10 +935,14 @@ func (d *Driver) AddDevice(deviceName string, protocols map[string]models.Protoc
 // when a Device associated with this Device Service is updated
 func (d *Driver) UpdateDevice(deviceName string, protocols map[string]models.ProtocolProperties, adminState models.AdminState) error {
 	d.Logger.Info(fmt.Sprintf("Device %s is updated", deviceName))
-	service := sdk.RunningService()
+	service := runningService()
 	device, err := service.GetDeviceByName(deviceName)
 	if err == nil {
 		Cache().UpdateObject(device)
+		// nguoi van hanh dat lai State = pending de provision lai thiet bi bi failed
+		if device.Profile.Name != managerProfileNameConst && provisionStatusOf(device).State == provisionPending {
+			provisioning().start(d, device)
+		}
 	}
 	return nil
 }
@@ -948,7 +952,15 @@ func (d *Driver) UpdateDevice(deviceName string, protocols map[string]models.Pro
 func (d *Driver) RemoveDevice(deviceName string, protocols map[string]models.ProtocolProperties) error {
 	d.Logger.Info(fmt.Sprintf("Device %s is removed", deviceName))
 	Cache().DeleteObject(deviceName)
-	return nil
+	metrics.forget(deviceName)
+	forgetObjectState(deviceName)
+
+	addr, ok := getObjectAddressFromProtocol(protocols)
+	if !ok {
+		// chua provision, khong co gi de xoa tren mang
+		return nil
+	}
+	return d.removeObject(deviceName, addr)
 }
 
 // func GetDriver() *Driver {
@@ -957,15 +969,16 @@ func (d *Driver) RemoveDevice(deviceName string, protocols map[string]models.Pro
 
 // PushEventGoroutine : chay gorountine de Push Event
 func PushEventGoroutine(data ResponseCommonFrame) {
-	objectID, ok := Cache().ConvertAddrToIDObject(data.ObjectAddress)
+	objectID, ok := objectOfFrame(data.ObjectInfo)
 	if !ok {
+		counters.update(func(s *LinkStats) { s.UnknownAddress++ })
 		return
 	}
 	objectName, ok := Cache().ConvertIDToNameObject(objectID)
 	if !ok {
 		return
 	}
-	resource, ok := Cache().ConvertAttToRes(data.AttributeInfo)
+	resource, ok := Cache().ConvertAttToRes(objectName, data.AttributeInfo)
 	if !ok {
 		return
 	}
@@ -974,15 +987,22 @@ func PushEventGoroutine(data ResponseCommonFrame) {
 		DeviceResourceName: resource.Name,
 		Type:               sdkModel.ParseValueType(resource.Properties.Value.Type),
 	}
-	result, err := newResult(req, data.Value)
+	reading, err := readingOf(req, data.AttributeInfo, data.Value)
+	if err != nil {
+		driver.Logger.Warn(fmt.Sprintf("PushEvent %s/%s: %v", objectName, resource.Name, err))
+		return
+	}
+	result, err := newResult(req, reading)
 	if err != nil {
 		return
 	}
+	saveValue(objectName, resource.Name, data.Value, result.Origin)
 	asyncValues := &sdkModel.AsyncValues{
 		DeviceName:    objectName,
 		CommandValues: []*sdkModel.CommandValue{result},
 	}
 
 	driver.AsyncCh <- asyncValues
+	metrics.observePushEvent(objectName)
 	driver.Logger.Info(fmt.Sprintf(" Pushed Event of Object=%s - value=%+v", objectName, data.Value))
 }
@@ -0,0 +1,257 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/device-zigbee/simulator"
+
+	sdkModel "github.com/edgexfoundry/device-sdk-go/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/models"
+)
+
+const (
+	e2eMAC     = "00124B0001A2B3C4"
+	e2eDevice  = "Dimmer-01"
+	e2eProfile = "DimmerProfile"
+)
+
+// e2eLevel : CurrentLevel cua Level cluster, uint8
+var e2eLevel = models.DeviceResource{
+	Name: "Level",
+	Attributes: map[string]string{
+		nameProfileID:   "260",
+		nameClusterID:   "8",
+		nameAttributeID: "0",
+		nameValueType:   "32",
+	},
+	Properties: models.ProfileProperty{
+		Value: models.PropertyValue{Type: "Uint8", ReadWrite: "RW"},
+	},
+}
+
+// memoryService : device service trong bo nho thay metadata.
+// UpdateDevice goi lai callback UpdateDevice cua driver nhu metadata
+type memoryService struct {
+	d       *Driver
+	config  map[string]string
+	profile models.DeviceProfile
+
+	mutex   sync.Mutex
+	devices map[string]models.Device
+	nextID  int
+}
+
+func newMemoryService(d *Driver, config map[string]string) *memoryService {
+	return &memoryService{
+		d:       d,
+		config:  config,
+		profile: models.DeviceProfile{Name: e2eProfile, DeviceResources: []models.DeviceResource{e2eLevel}},
+		devices: make(map[string]models.Device),
+	}
+}
+
+func (s *memoryService) Name() string                     { return "device-zigbee-e2e" }
+func (s *memoryService) DriverConfigs() map[string]string { return s.config }
+func (s *memoryService) MetadataURL() (string, error)     { return "http://localhost:48081", nil }
+
+func (s *memoryService) AddRoute(string, func(http.ResponseWriter, *http.Request), ...string) error {
+	return nil
+}
+
+func (s *memoryService) Devices() []models.Device {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]models.Device, 0, len(s.devices))
+	for _, device := range s.devices {
+		result = append(result, device)
+	}
+	return result
+}
+
+func (s *memoryService) GetDeviceByName(name string) (models.Device, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	device, ok := s.devices[name]
+	if !ok {
+		return device, fmt.Errorf("device %s not found", name)
+	}
+	return device, nil
+}
+
+func (s *memoryService) AddDevice(device models.Device) (string, error) {
+	s.mutex.Lock()
+	s.nextID++
+	device.Id = fmt.Sprintf("id-%d", s.nextID)
+	device.Profile = s.profile
+	s.devices[device.Name] = device
+	s.mutex.Unlock()
+
+	return device.Id, s.d.AddDevice(device.Name, device.Protocols, device.AdminState)
+}
+
+func (s *memoryService) UpdateDevice(device models.Device) error {
+	s.mutex.Lock()
+	if _, ok := s.devices[device.Name]; !ok {
+		s.mutex.Unlock()
+		return fmt.Errorf("device %s not found", device.Name)
+	}
+	s.devices[device.Name] = device
+	s.mutex.Unlock()
+
+	return s.d.UpdateDevice(device.Name, device.Protocols, device.AdminState)
+}
+
+func (s *memoryService) DeviceProfiles() []models.DeviceProfile {
+	return []models.DeviceProfile{s.profile}
+}
+
+func (s *memoryService) DeviceResource(deviceName string, deviceResource string, method string) (models.DeviceResource, bool) {
+	for _, dr := range s.profile.DeviceResources {
+		if dr.Name == deviceResource {
+			return dr, true
+		}
+	}
+	return models.DeviceResource{}, false
+}
+
+// TestEndToEnd : driver va simulator noi qua PipeTransport:
+// provision thiet bi, doc, ghi va nhan PushEvent
+func TestEndToEnd(t *testing.T) {
+	d := NewProtocolDriver().(*Driver)
+	svc := newMemoryService(d, map[string]string{
+		nameTransportConfig:              pipeTransportType,
+		nameReconcileIntervalConfig:      "0",
+		nameProvisionRetryIntervalConfig: "50",
+		nameRequestTimeoutConfig:         "5000",
+	})
+	setRunningService(svc)
+	defer setRunningService(nil)
+
+	asyncCh := make(chan *sdkModel.AsyncValues, 16)
+	err := d.Initialize(logger.NewMockClient(), asyncCh)
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer d.Stop(true)
+
+	pipe, ok := CurrentPipeTransport()
+	if !ok || pipe.Peer() == nil {
+		t.Fatal("pipe transport is not open")
+	}
+	sim := simulator.New(simulator.Config{
+		PAN:  0x1A62,
+		Logf: t.Logf,
+		Devices: []simulator.Device{{
+			MAC:  e2eMAC,
+			Name: e2eDevice,
+			Attributes: []simulator.Attribute{
+				{ProfileID: 260, ClusterID: 0, AttributeID: 0, ValueType: 0x20, Value: 3, ReadOnly: true},
+				{ProfileID: 260, ClusterID: 8, AttributeID: 0, ValueType: 0x20, Value: 254, Report: true},
+			},
+		}},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- sim.Serve(ctx, pipe.Peer()) }()
+	defer func() {
+		cancel()
+		<-served
+	}()
+
+	// provisioning
+	_, err = svc.AddDevice(models.Device{
+		Name:       e2eDevice,
+		AdminState: models.Unlocked,
+		Labels:     []string{DEVICETYPE, UNINITIALIZIED},
+		Protocols: map[string]models.ProtocolProperties{
+			nameNetworkProtocol: {nameMACProperty: e2eMAC},
+		},
+	})
+	if err != nil {
+		t.Fatalf("AddDevice: %v", err)
+	}
+	var device models.Device
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		device, _ = svc.GetDeviceByName(e2eDevice)
+		st := provisionStatusOf(device)
+		if st.State == provisionInterviewed {
+			break
+		}
+		if st.State == provisionFailed || time.Now().After(deadline) {
+			t.Fatalf("provision status = %+v", st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := getObjectAddressFromProtocol(device.Protocols); !ok {
+		t.Fatalf("provisioned device has no address: %v", device.Protocols)
+	}
+	if !labelsType(device.Labels).isInitializied() {
+		t.Fatalf("provisioned device labels = %v", device.Labels)
+	}
+
+	// doc
+	req := sdkModel.CommandRequest{
+		DeviceResourceName: e2eLevel.Name,
+		Attributes:         e2eLevel.Attributes,
+		Type:               sdkModel.Uint8,
+	}
+	values, err := d.HandleReadCommands(e2eDevice, device.Protocols, []sdkModel.CommandRequest{req})
+	if err != nil {
+		t.Fatalf("HandleReadCommands: %v", err)
+	}
+	if v, err := values[0].Uint8Value(); err != nil || v != 254 {
+		t.Fatalf("read Level = %v (%v), want 254", v, err)
+	}
+
+	// ghi
+	param, _ := sdkModel.NewUint8Value(e2eLevel.Name, 0, 100)
+	err = d.HandleWriteCommands(e2eDevice, device.Protocols, []sdkModel.CommandRequest{req}, []*sdkModel.CommandValue{param})
+	if err != nil {
+		t.Fatalf("HandleWriteCommands: %v", err)
+	}
+	if v := simulatorValue(sim, e2eMAC, 8, 0); fmt.Sprint(v) != "100" {
+		t.Fatalf("simulator Level = %v after write, want 100", v)
+	}
+
+	// PushEvent
+	err = sim.SetAttribute(e2eMAC, 8, 0, 42)
+	if err != nil {
+		t.Fatalf("SetAttribute: %v", err)
+	}
+	select {
+	case av := <-asyncCh:
+		if av.DeviceName != e2eDevice || len(av.CommandValues) != 1 {
+			t.Fatalf("pushed event = %+v", av)
+		}
+		cv := av.CommandValues[0]
+		if v, err := cv.Uint8Value(); cv.DeviceResourceName != e2eLevel.Name || err != nil || v != 42 {
+			t.Fatalf("pushed reading = %v", cv)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pushed event")
+	}
+}
+
+// simulatorValue : gia tri attribute cua thiet bi ao
+func simulatorValue(sim *simulator.Simulator, mac string, clusterID uint16, attributeID uint16) interface{} {
+	for _, dev := range sim.Devices() {
+		if dev.MAC != mac {
+			continue
+		}
+		for _, att := range dev.Attributes {
+			if att.ClusterID == clusterID && att.AttributeID == attributeID {
+				return att.Value
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/device-zigbee/driver/zcl"
+)
+
+// Cac loi tra ve cho EdgeX (va qua do toi client) khi doc/ghi/provision doi tuong.
+// So sanh truc tiep (err == ErrTimeout) hoac kiem tra kieu ZCLStatusError, UnknownOwnerError.
+var (
+	// ErrObjectUnknown : ten doi tuong khong co trong cache
+	ErrObjectUnknown = errors.New("zigbee: unknown object")
+	// ErrObjectNoAddress : doi tuong chua duoc coordinator cap dia chi mang (chua provision)
+	ErrObjectNoAddress = errors.New("zigbee: object has no network address")
+	// ErrAttributeUnmapped : resource khong co profileID/clusterID/attributeID/valueType
+	ErrAttributeUnmapped = errors.New("zigbee: resource is not mapped to a Zigbee attribute")
+	// ErrTimeout : het thoi gian cho gui hoac cho phan hoi
+	ErrTimeout = errors.New("zigbee: timed out waiting for the coordinator")
+	// ErrCanceled : yeu cau bi huy (driver Stop)
+	ErrCanceled = errors.New("zigbee: request canceled")
+	// ErrLinkDown : mat ket noi toi coordinator, cac yeu cau dang cho ket thuc ngay
+	ErrLinkDown = errors.New("zigbee: link to coordinator is down")
+	// ErrBadResponse : phan hoi khong dung dinh dang
+	ErrBadResponse = errors.New("zigbee: malformed response")
+	// ErrBusy : het so thu tu (seq) cho yeu cau dang cho phan hoi
+	ErrBusy = errors.New("zigbee: too many outstanding requests")
+	// ErrInvalidRequest : lenh cua manager device thieu tham so
+	ErrInvalidRequest = errors.New("zigbee: invalid manager request")
+)
+
+// UnknownOwnerError : owner cua muc Subscribe/Schedule khong co trong cache.
+// Unwrap tra ve ErrObjectUnknown
+type UnknownOwnerError struct {
+	OwnerID string
+}
+
+func (e UnknownOwnerError) Error() string {
+	return fmt.Sprintf("%v: owner %s", ErrObjectUnknown, e.OwnerID)
+}
+
+func (e UnknownOwnerError) Unwrap() error {
+	return ErrObjectUnknown
+}
+
+// errEncodeFrame : khong dong goi duoc frame gui di (loi cua driver)
+var errEncodeFrame = errors.New("zigbee: cannot encode frame")
+
+// contextError : doi loi cua context sang ErrTimeout/ErrCanceled
+func contextError(err error) error {
+	switch err {
+	case context.DeadlineExceeded:
+		return ErrTimeout
+	case context.Canceled:
+		return ErrCanceled
+	}
+	return err
+}
+
+// isClientError : loi do yeu cau (doi tuong, resource, gia tri), khong phai do duong truyen
+func isClientError(err error) bool {
+	switch err.(type) {
+	case ZCLStatusError, UnknownOwnerError, *zcl.RangeError, *zcl.TypeError:
+		return true
+	}
+	return err == ErrObjectUnknown || err == ErrObjectNoAddress || err == ErrAttributeUnmapped ||
+		err == ErrInvalidRequest
+}
+
+// logCommandError : log loi doc/ghi cung 1 dang; loi do yeu cau o muc Warn, con lai o muc Error
+func logCommandError(operation string, objectName string, resource string, err error) {
+	target := objectName
+	if resource != "" {
+		target += "/" + resource
+	}
+	msg := fmt.Sprintf("%s %s failed: %v", operation, target, err)
+	if isClientError(err) {
+		driver.Logger.Warn(msg)
+		return
+	}
+	driver.Logger.Error(msg)
+}
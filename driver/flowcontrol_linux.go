@@ -0,0 +1,39 @@
+// +build linux
+
+package driver
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// setFlowControl : bat flow control cho cong serial da mo.
+// termios gan voi thiet bi tty nen co the sua qua mot fd khac voi fd cua tarm/serial
+func setFlowControl(name string, flow string) error {
+	if flow == flowControlNone {
+		return nil
+	}
+	f, err := os.OpenFile(name, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fd := int(f.Fd())
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	switch flow {
+	case flowControlRTSCTS:
+		t.Cflag |= unix.CRTSCTS
+	case flowControlXONXOFF:
+		t.Iflag |= unix.IXON | unix.IXOFF
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(unix.TCSETS), uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
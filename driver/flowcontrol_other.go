@@ -0,0 +1,13 @@
+// +build !linux
+
+package driver
+
+import "fmt"
+
+// setFlowControl : chi ho tro flow control tren linux
+func setFlowControl(name string, flow string) error {
+	if flow == flowControlNone {
+		return nil
+	}
+	return fmt.Errorf("FlowControl %s chi ho tro tren linux", flow)
+}
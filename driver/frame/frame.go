@@ -0,0 +1,254 @@
+// Package frame : dong goi va tach frame UART giua driver va coordinator.
+//
+//	| Header 0x55 | Lenght (2 byte, big endian) | Cmd | Payload | CRC |
+//
+// Lenght = len(Payload) + size(Cmd), CRC = tong cac byte truoc no (mod 256).
+// Ca driver va simulator deu dung package nay cho ca hai chieu.
+package frame
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Header : byte bat dau cua moi frame
+const Header = 0x55
+
+const (
+	// MaxLength : gia tri lon nhat cua truong Lenght (int16)
+	MaxLength = 0x7FFF
+	// DefaultMaxLength : Decoder bo cac frame dai hon, tranh nuot nhieu frame sau 1 byte Lenght bi nhieu
+	DefaultMaxLength = 2048
+	// overhead : Header + Lenght + CRC
+	overhead = 4
+)
+
+// UARTFrame :	TX-RX UART frame
+type UARTFrame struct {
+	Header  byte
+	Lenght  int16 // Lenght = len(Payload) + size(Cmd)
+	Cmd     byte
+	Payload []byte
+	CRC     byte
+}
+
+// ErrShortRead : het du lieu (hoac het ReadTimeout) giua frame
+var ErrShortRead = errors.New("frame: het du lieu giua frame")
+
+// CRCError : CRC nhan duoc khac CRC tinh lai
+type CRCError struct {
+	Frame UARTFrame
+	Want  byte
+}
+
+func (e *CRCError) Error() string {
+	return fmt.Sprintf("frame: sai CRC cmd=%d: nhan 0x%02X, tinh duoc 0x%02X", e.Frame.Cmd, e.Frame.CRC, e.Want)
+}
+
+// LengthError : truong Lenght khong hop le (qua ngan hoac qua dai)
+type LengthError struct {
+	Lenght int
+	Max    int
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("frame: do dai %d khong hop le (2-%d)", e.Lenght, e.Max)
+}
+
+// IsFrameError : loi cua 1 frame, Decoder van dung duoc cho frame tiep theo
+func IsFrameError(err error) bool {
+	switch err.(type) {
+	case *CRCError, *LengthError:
+		return true
+	}
+	return err == ErrShortRead
+}
+
+// New : tao frame voi Header, Lenght va CRC da tinh
+func New(cmd byte, payload []byte) UARTFrame {
+	f := UARTFrame{
+		Header:  Header,
+		Lenght:  int16(len(payload) + 1), // 1 = size(cmd)
+		Cmd:     cmd,
+		Payload: payload,
+	}
+	f.CRC = Checksum(f)
+	return f
+}
+
+// Checksum : CRC cua frame
+func Checksum(f UARTFrame) byte {
+	crc := byte(Header) + byte(f.Lenght>>8) + byte(f.Lenght) + f.Cmd
+	for _, b := range f.Payload {
+		crc += b
+	}
+	return crc
+}
+
+// Size : so byte cua frame khi gui
+func (f UARTFrame) Size() int {
+	return len(f.Payload) + 1 + overhead
+}
+
+// Encode : chuyen frame thanh []byte, Header/Lenght/CRC duoc tinh lai tu Cmd va Payload.
+// Payload rong bi tu choi giong Decoder (Lenght toi thieu 2)
+func Encode(f UARTFrame) ([]byte, error) {
+	if len(f.Payload) == 0 || len(f.Payload)+1 > MaxLength {
+		return nil, &LengthError{Lenght: len(f.Payload) + 1, Max: MaxLength}
+	}
+	return New(f.Cmd, f.Payload).Bytes(), nil
+}
+
+// Bytes : cac byte cua frame dung nhu da nhan, khong tinh lai Lenght/CRC
+func (f UARTFrame) Bytes() []byte {
+	b := make([]byte, 0, f.Size())
+	b = append(b, f.Header, byte(f.Lenght>>8), byte(f.Lenght), f.Cmd)
+	b = append(b, f.Payload...)
+	b = append(b, f.CRC)
+	return b
+}
+
+// Decoder : tach frame tu io.Reader qua bo dem, moi lan Read lay nhieu byte nhat co the.
+// Khi gap frame loi (sai Lenght/CRC, thieu byte) chi bo byte Header cua no roi tim Header
+// tiep theo trong phan da dem, nen frame tot nam ngay sau (hoac ben trong) frame loi khong bi mat.
+// Read tra ve (0, nil) (het ReadTimeout cua cong serial) truoc Header duoc bo qua,
+// giua frame thi Decode tra ve ErrShortRead.
+type Decoder struct {
+	r          io.Reader
+	maxLength  int
+	buf        []byte
+	start, end int   // du lieu chua xu ly: buf[start:end]
+	err        error // loi cua Read, tra ve sau khi xu ly het du lieu da dem
+	stats      DecoderStats
+	skipping   bool // dang bo byte de tim Header
+}
+
+// DecoderStats : so lan Decoder phai bo byte de tim lai Header
+type DecoderStats struct {
+	Resyncs        uint64 // so doan byte lien tiep bi bo
+	DiscardedBytes uint64 // tong so byte bi bo
+}
+
+// NewDecoder : Decoder voi do dai frame toi da maxLength, 0 = DefaultMaxLength
+func NewDecoder(r io.Reader, maxLength int) *Decoder {
+	if maxLength <= 0 || maxLength > MaxLength {
+		maxLength = DefaultMaxLength
+	}
+	return &Decoder{
+		r:         r,
+		maxLength: maxLength,
+		buf:       make([]byte, maxLength+overhead),
+	}
+}
+
+// Decode : doc frame tiep theo. Loi cua frame (IsFrameError) khong lam hong Decoder,
+// cac loi khac la loi cua io.Reader
+func (d *Decoder) Decode() (UARTFrame, error) {
+	for {
+		i := bytes.IndexByte(d.buf[d.start:d.end], Header)
+		if i < 0 {
+			d.discard(d.end - d.start)
+			d.start, d.end = 0, 0
+		} else {
+			d.discard(i)
+			d.start += i
+		}
+		partial := i >= 0
+
+		if avail := d.end - d.start; partial && avail >= 3 {
+			length := int(d.buf[d.start+1])<<8 | int(d.buf[d.start+2])
+			if length <= 1 || length > d.maxLength {
+				d.dropHeader()
+				return UARTFrame{}, &LengthError{Lenght: length, Max: d.maxLength}
+			}
+			if avail >= length+overhead {
+				f := d.frameAt(d.start, length)
+				if want := Checksum(f); want != f.CRC {
+					d.dropHeader()
+					return f, &CRCError{Frame: f, Want: want}
+				}
+				d.start += length + overhead
+				d.skipping = false
+				return f, nil
+			}
+		}
+
+		n, err := d.fill()
+		if n > 0 {
+			continue
+		}
+		if err != nil {
+			if partial && err == io.EOF {
+				d.dropHeader()
+				return UARTFrame{}, ErrShortRead
+			}
+			return UARTFrame{}, err
+		}
+		if partial {
+			// het ReadTimeout giua frame
+			d.dropHeader()
+			return UARTFrame{}, ErrShortRead
+		}
+	}
+}
+
+// dropHeader : bo byte Header cua frame loi, tim Header tiep theo tu byte sau no
+func (d *Decoder) dropHeader() {
+	d.discard(1)
+	d.start++
+}
+
+// Stats : thong ke tu khi tao Decoder
+func (d *Decoder) Stats() DecoderStats {
+	return d.stats
+}
+
+// discard : dem n byte bi bo, cac byte bo lien tiep (khong xen frame tot) tinh la 1 lan resync
+func (d *Decoder) discard(n int) {
+	if n <= 0 {
+		return
+	}
+	if !d.skipping {
+		d.stats.Resyncs++
+		d.skipping = true
+	}
+	d.stats.DiscardedBytes += uint64(n)
+}
+
+// frameAt : sao chep frame tai buf[pos:], bo dem se bi ghi de o lan doc sau
+func (d *Decoder) frameAt(pos int, length int) UARTFrame {
+	payload := make([]byte, length-1)
+	copy(payload, d.buf[pos+4:pos+3+length])
+	return UARTFrame{
+		Header:  Header,
+		Lenght:  int16(length),
+		Cmd:     d.buf[pos+3],
+		Payload: payload,
+		CRC:     d.buf[pos+3+length],
+	}
+}
+
+// fill : doc them du lieu vao cuoi bo dem
+func (d *Decoder) fill() (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.start > 0 {
+		d.end = copy(d.buf, d.buf[d.start:d.end])
+		d.start = 0
+	}
+	n, err := d.r.Read(d.buf[d.end:])
+	if n < 0 {
+		n = 0
+	}
+	d.end += n
+	if err != nil {
+		d.err = err
+		if n > 0 {
+			return n, nil
+		}
+	}
+	return n, err
+}
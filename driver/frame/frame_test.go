@@ -0,0 +1,153 @@
+package frame
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     byte
+		payload []byte
+	}{
+		{"command", 0, []byte(`{"addr":20257,"type":1,"endp":1,"seq":7,"cmid":1,"pro":260,"clu":6,"att":0,"vltp":16}`)},
+		{"push event", 1, []byte(`{"addr":20257,"type":1,"endp":1,"resp":0,"pro":260,"clu":6,"att":0,"vltp":16,"val":true}`)},
+		{"one byte payload", 4, []byte{0}},
+		{"payload with header bytes", 2, []byte{Header, Header, 0x00, 0x02, Header}},
+		{"binary", 0xFF, bytes.Repeat([]byte{0x00, 0xFF, 0x55, 0xAA}, 64)},
+		{"max length", 3, bytes.Repeat([]byte{'x'}, DefaultMaxLength-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Encode(New(tt.cmd, tt.payload))
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if len(b) != len(tt.payload)+1+overhead {
+				t.Fatalf("len(Encode) = %d, want %d", len(b), len(tt.payload)+1+overhead)
+			}
+			f, err := NewDecoder(bytes.NewReader(b), 0).Decode()
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if f.Cmd != tt.cmd || !bytes.Equal(f.Payload, tt.payload) || f.CRC != Checksum(f) {
+				t.Fatalf("Decode = %+v, want cmd=%d payload=%q", f, tt.cmd, tt.payload)
+			}
+			if !bytes.Equal(f.Bytes(), b) {
+				t.Fatalf("Bytes = % X, want % X", f.Bytes(), b)
+			}
+		})
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	var stream []byte
+	for i := 0; i < 3; i++ {
+		b, _ := Encode(New(byte(i), []byte{byte('a' + i)}))
+		stream = append(stream, b...)
+	}
+	// 1 byte moi lan Read, nhu cong serial cham
+	d := NewDecoder(&oneByteReader{bytes.NewReader(stream)}, 0)
+	for i := 0; i < 3; i++ {
+		f, err := d.Decode()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if f.Cmd != byte(i) || string(f.Payload) != string(rune('a'+i)) {
+			t.Fatalf("frame %d = %+v", i, f)
+		}
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Fatalf("Decode after last frame: %v, want io.EOF", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	good, _ := Encode(New(0, []byte(`{"resp":0}`)))
+	badCRC := append([]byte(nil), good...)
+	badCRC[len(badCRC)-1]++
+	oversize := []byte{Header, 0x10, 0x00, 0x00}
+
+	t.Run("bad CRC", func(t *testing.T) {
+		_, err := NewDecoder(bytes.NewReader(badCRC), 0).Decode()
+		e, ok := err.(*CRCError)
+		if !ok {
+			t.Fatalf("err = %v (%T), want *CRCError", err, err)
+		}
+		if e.Want != good[len(good)-1] || !IsFrameError(err) {
+			t.Fatalf("CRCError = %+v", e)
+		}
+	})
+	t.Run("short read", func(t *testing.T) {
+		_, err := NewDecoder(bytes.NewReader(good[:len(good)-3]), 0).Decode()
+		if err != ErrShortRead || !IsFrameError(err) {
+			t.Fatalf("err = %v, want ErrShortRead", err)
+		}
+	})
+	t.Run("oversize length", func(t *testing.T) {
+		_, err := NewDecoder(bytes.NewReader(oversize), 0).Decode()
+		e, ok := err.(*LengthError)
+		if !ok {
+			t.Fatalf("err = %v (%T), want *LengthError", err, err)
+		}
+		if e.Lenght != 0x1000 || e.Max != DefaultMaxLength || !IsFrameError(err) {
+			t.Fatalf("LengthError = %+v", e)
+		}
+	})
+	t.Run("encode oversize", func(t *testing.T) {
+		_, err := Encode(UARTFrame{Payload: make([]byte, MaxLength)})
+		if _, ok := err.(*LengthError); !ok {
+			t.Fatalf("err = %v (%T), want *LengthError", err, err)
+		}
+	})
+	t.Run("empty payload", func(t *testing.T) {
+		_, err := Encode(UARTFrame{Cmd: 4})
+		if _, ok := err.(*LengthError); !ok {
+			t.Fatalf("Encode: err = %v (%T), want *LengthError", err, err)
+		}
+		_, err = NewDecoder(bytes.NewReader([]byte{Header, 0x00, 0x01, 0x04, Header + 0x05}), 0).Decode()
+		if _, ok := err.(*LengthError); !ok {
+			t.Fatalf("Decode: err = %v (%T), want *LengthError", err, err)
+		}
+	})
+	t.Run("resync after bad frames", func(t *testing.T) {
+		var stream []byte
+		stream = append(stream, 0x00, 0x13)
+		stream = append(stream, badCRC...)
+		stream = append(stream, oversize...)
+		stream = append(stream, good...)
+		d := NewDecoder(bytes.NewReader(stream), 0)
+		var frameErrs int
+		for {
+			f, err := d.Decode()
+			if IsFrameError(err) {
+				frameErrs++
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !bytes.Equal(f.Bytes(), good) {
+				t.Fatalf("Decode = % X, want % X", f.Bytes(), good)
+			}
+			break
+		}
+		if frameErrs == 0 || d.Stats().Resyncs == 0 {
+			t.Fatalf("frame errors = %d, stats = %+v", frameErrs, d.Stats())
+		}
+	})
+}
+
+// oneByteReader : tra ve tung byte moi lan Read
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (o *oneByteReader) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(b[:1])
+}
@@ -0,0 +1,49 @@
+//go:build go1.18
+// +build go1.18
+
+package frame
+
+import (
+	"bytes"
+	"testing"
+)
+
+// FuzzDecode : Decoder khong duoc panic, moi frame Decode duoc phai Encode lai dung tung byte
+func FuzzDecode(f *testing.F) {
+	good, _ := Encode(New(0, []byte(`{"addr":1,"seq":1,"resp":0}`)))
+	badCRC := append([]byte(nil), good...)
+	badCRC[len(badCRC)-1]++
+	f.Add(good)
+	f.Add(badCRC)
+	f.Add([]byte{Header, 0x00, 0x01, 0x04, Header + 0x05})
+	f.Add(append(append([]byte{0x00, Header}, good...), good[:5]...))
+	f.Add([]byte{Header, 0x10, 0x00, 0x00})
+	f.Add([]byte{Header, 0x00, 0x01, 0x00, Header})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d := NewDecoder(bytes.NewReader(data), 0)
+		for {
+			fr, err := d.Decode()
+			if IsFrameError(err) {
+				continue
+			}
+			if err != nil {
+				return
+			}
+			b, err := Encode(fr)
+			if err != nil {
+				t.Fatalf("Encode(%+v): %v", fr, err)
+			}
+			if !bytes.Equal(b, fr.Bytes()) {
+				t.Fatalf("Encode = % X, want % X", b, fr.Bytes())
+			}
+			again, err := NewDecoder(bytes.NewReader(b), 0).Decode()
+			if err != nil {
+				t.Fatalf("Decode(Encode(f)): %v", err)
+			}
+			if again.Cmd != fr.Cmd || !bytes.Equal(again.Payload, fr.Payload) || again.CRC != fr.CRC {
+				t.Fatalf("Decode(Encode(f)) = %+v, want %+v", again, fr)
+			}
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package driver
+
+import (
+	"strconv"
+	"time"
+
+	sdkModel "github.com/edgexfoundry/device-sdk-go/pkg/models"
+)
+
+// nameMaxAgeAttribute : thuoc tinh cua resource trong profile, tuoi toi da (ms) cua gia tri cuoi
+// (tu PushEvent hoac lan doc truoc) de tra loi lenh doc khong can gui qua mang. 0/khong co = luon doc
+const nameMaxAgeAttribute = "maxAge"
+
+// maxAgeOf : maxAge cua resource, 0 neu khong co hoac khong hop le
+func maxAgeOf(req sdkModel.CommandRequest) time.Duration {
+	v, ok := req.Attributes[nameMaxAgeAttribute]
+	if !ok {
+		return 0
+	}
+	ms, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// lookupValue : gia tri cuoi cua resource trong kho trang thai
+func lookupValue(objectName string, resource string) (lastValue, bool) {
+	var v lastValue
+	ok, err := stateStore().Get(bucketValues, valueKey(objectName, resource), &v)
+	if err != nil {
+		logStateError("load value "+valueKey(objectName, resource), err)
+		return v, false
+	}
+	if ok {
+		v.Origin = valueOrigin(valueKey(objectName, resource), v.Origin)
+	}
+	return v, ok
+}
+
+// forgetValue : bo gia tri cuoi sau khi ghi, lan doc sau se hoi lai thiet bi
+func forgetValue(objectName string, resource string) {
+	logStateError("forget value "+valueKey(objectName, resource), stateStore().Delete(bucketValues, valueKey(objectName, resource)))
+	forgetValueOrigin(valueKey(objectName, resource))
+}
+
+// forgetMemberValues : ghi vao group/scenario doi gia tri cua cac thiet bi thanh vien.
+// Group: bo gia tri cua cung thuoc tinh tren thanh vien (ten resource theo profile cua thanh vien);
+// scenario co the chay lenh bat ky nen bo moi gia tri cua thanh vien
+func forgetMemberValues(objectName string, resource string) {
+	object, err := runningService().GetDeviceByName(objectName)
+	if err != nil || !isGroupOrScenario(object) {
+		return
+	}
+	members := membersOf(object.Id)
+	if labelsType(object.Labels).getType() == SCENARIOTYPE {
+		for _, member := range members {
+			forgetObjectValues(member)
+		}
+		return
+	}
+	att, ok := Cache().ConvertResToAtt(objectName, resource)
+	if !ok {
+		return
+	}
+	for _, member := range members {
+		if res, ok := Cache().ConvertAttToRes(member, att); ok {
+			forgetValue(member, res.Name)
+		}
+	}
+}
+
+// cachedReading : ket qua lenh doc tu gia tri cuoi neu chua qua maxAge cua resource.
+// Origin cua ket qua la thoi diem nhan gia tri tu thiet bi
+func cachedReading(objectName string, req sdkModel.CommandRequest) (*sdkModel.CommandValue, bool) {
+	maxAge := maxAgeOf(req)
+	if maxAge <= 0 || isLocalResource(req.DeviceResourceName) {
+		return nil, false
+	}
+	v, ok := lookupValue(objectName, req.DeviceResourceName)
+	if !ok || time.Since(time.Unix(0, v.Origin)) > maxAge {
+		return nil, false
+	}
+	att, ok := Cache().ConvertResToAtt(objectName, req.DeviceResourceName)
+	if !ok {
+		return nil, false
+	}
+	reading, err := readingOf(req, att, v.Value)
+	if err != nil {
+		return nil, false
+	}
+	result, err := newResult(req, reading)
+	if err != nil {
+		return nil, false
+	}
+	result.Origin = v.Origin
+	return result, true
+}
@@ -0,0 +1,167 @@
+package driver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/device-zigbee/driver/packet"
+)
+
+// LinkState : trang thai ket noi toi coordinator
+type LinkState int
+
+const (
+	// LinkDown : mat ket noi, dang thu ket noi lai
+	LinkDown LinkState = iota
+	// LinkUp : ket noi binh thuong
+	LinkUp
+	// LinkClosed : da dong boi Stop(), khong ket noi lai
+	LinkClosed
+)
+
+func (s LinkState) String() string {
+	switch s {
+	case LinkUp:
+		return "up"
+	case LinkDown:
+		return "down"
+	case LinkClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+const (
+	reconnectMinDelay = 500 * time.Millisecond
+	reconnectMaxDelay = 30 * time.Second
+)
+
+type linkSupervisor struct {
+	mutex  sync.Mutex
+	t      Transport
+	state  LinkState
+	gen    int           // tang moi lan ket noi lai, loi cua ket noi cu bi bo qua
+	rxDone chan struct{} // dong khi receiverUartRoutine cua ket noi hien tai ket thuc
+}
+
+var link *linkSupervisor
+
+func newLinkSupervisor(t Transport) *linkSupervisor {
+	return &linkSupervisor{
+		t:     t,
+		state: LinkDown,
+	}
+}
+
+// up : danh dau ket noi da mo va chay receiver cho ket noi moi
+func (l *linkSupervisor) up() {
+	l.mutex.Lock()
+	if l.state == LinkClosed {
+		l.mutex.Unlock()
+		l.t.Close()
+		return
+	}
+	l.state = LinkUp
+	l.gen++
+	l.rxDone = make(chan struct{})
+	gen, rxDone := l.gen, l.rxDone
+	l.mutex.Unlock()
+
+	go func() {
+		receiverUartRoutine(l.t, gen)
+		close(rxDone)
+	}()
+}
+
+// fail : bao loi doc/ghi cua ket noi the he gen, chi loi dau tien cua moi the he duoc xu ly
+func (l *linkSupervisor) fail(gen int, err error) {
+	l.mutex.Lock()
+	if l.state != LinkUp || gen != l.gen {
+		l.mutex.Unlock()
+		return
+	}
+	l.state = LinkDown
+	rxDone := l.rxDone
+	l.mutex.Unlock()
+	counters.update(func(s *LinkStats) { s.LinkFailures++ })
+
+	// cac yeu cau dang cho phan hoi ket thuc ngay voi ErrLinkDown
+	packet.Repo().FailAll(ErrLinkDown)
+
+	if driver != nil && driver.Logger != nil {
+		driver.Logger.Error(fmt.Sprintf("Mat ket noi toi coordinator: %v", err))
+	}
+	go l.recover(rxDone)
+}
+
+// recover : dong ket noi cu, cho receiver ket thuc roi ket noi lai
+func (l *linkSupervisor) recover(rxDone chan struct{}) {
+	l.t.Close()
+	<-rxDone
+
+	if !l.reconnect() {
+		return
+	}
+	if driver != nil && driver.Logger != nil {
+		driver.Logger.Info("Da ket noi lai toi coordinator")
+	}
+	l.up()
+}
+
+// reconnect : mo lai ket noi voi backoff tang dan, tra ve false neu link da bi dong
+func (l *linkSupervisor) reconnect() bool {
+	delay := reconnectMinDelay
+	for {
+		time.Sleep(delay)
+		if l.State() == LinkClosed {
+			return false
+		}
+		err := l.t.Open()
+		if err == nil {
+			return true
+		}
+		if driver != nil && driver.Logger != nil {
+			driver.Logger.Warn(fmt.Sprintf("Ket noi lai that bai, thu lai sau %v: %v", delay, err))
+		}
+		delay *= 2
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+}
+
+func (l *linkSupervisor) close() {
+	l.mutex.Lock()
+	l.state = LinkClosed
+	l.mutex.Unlock()
+	l.t.Close()
+	packet.Repo().FailAll(ErrLinkDown)
+}
+
+// State : trang thai ket noi hien tai
+func (l *linkSupervisor) State() LinkState {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.state
+}
+
+// generation : the he ket noi hien tai
+func (l *linkSupervisor) generation() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.gen
+}
+
+// CurrentLinkState : trang thai ket noi toi coordinator
+func CurrentLinkState() LinkState {
+	if link == nil {
+		return LinkDown
+	}
+	return link.State()
+}
+
+// LinkIsUp : true neu co the gui lenh toi coordinator
+func LinkIsUp() bool {
+	return CurrentLinkState() == LinkUp
+}
@@ -0,0 +1,333 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsRoute : REST endpoint tra ve metrics dang Prometheus text (version 0.0.4)
+const metricsRoute = "/metrics"
+
+// cac gia tri cua nhan operation / outcome
+const (
+	metricOpRead       = "read"
+	metricOpReadCached = "read_cached" // tra loi tu gia tri cuoi, xem cachedReading
+	metricOpWrite      = "write"
+
+	metricStatusLinkDown   = "LINK_DOWN"
+	metricStatusNoResponse = "NO_RESPONSE"
+	metricStatusError      = "ERROR"
+)
+
+// latencyBuckets : can tren cac bucket cua histogram thoi gian lenh (s)
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64 // theo latencyBuckets, khong cong don
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	for i, b := range latencyBuckets {
+		if v <= b {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// deviceLabels : nhan device/profile lay tu object cache
+type deviceLabels struct {
+	device  string
+	profile string
+}
+
+type commandKey struct {
+	deviceLabels
+	operation string
+}
+
+type commandStatusKey struct {
+	commandKey
+	status string
+}
+
+type provisionKey struct {
+	deviceLabels
+	outcome string
+}
+
+type metricsRegistry struct {
+	mutex        sync.Mutex
+	latency      map[commandKey]*histogram
+	commands     map[commandStatusKey]uint64
+	pushEvents   map[deviceLabels]uint64
+	provisioning map[provisionKey]uint64
+}
+
+var metrics = metricsRegistry{
+	latency:      make(map[commandKey]*histogram),
+	commands:     make(map[commandStatusKey]uint64),
+	pushEvents:   make(map[deviceLabels]uint64),
+	provisioning: make(map[provisionKey]uint64),
+}
+
+func labelsOf(objectName string) deviceLabels {
+	profile, _ := Cache().ConvertNameToProfile(objectName)
+	return deviceLabels{device: objectName, profile: profile}
+}
+
+// metricStatus : nhan status cua ket qua lenh, ma ZCL neu thiet bi tra loi
+func metricStatus(err error) string {
+	switch e := err.(type) {
+	case nil:
+		return ZCLStatusName(0)
+	case ZCLStatusError:
+		return e.Name()
+	}
+	switch err {
+	case ErrTimeout:
+		return metricStatusNoResponse
+	case ErrLinkDown:
+		return metricStatusLinkDown
+	}
+	return metricStatusError
+}
+
+// observeCommand : ghi thoi gian va ket qua 1 lenh doc/ghi
+func (m *metricsRegistry) observeCommand(objectName string, operation string, start time.Time, err error) {
+	key := commandKey{deviceLabels: labelsOf(objectName), operation: operation}
+	status := metricStatus(err)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	h, ok := m.latency[key]
+	if !ok {
+		h = &histogram{}
+		m.latency[key] = h
+	}
+	h.observe(time.Since(start).Seconds())
+	m.commands[commandStatusKey{commandKey: key, status: status}]++
+}
+
+func (m *metricsRegistry) observePushEvent(objectName string) {
+	l := labelsOf(objectName)
+	m.mutex.Lock()
+	m.pushEvents[l]++
+	m.mutex.Unlock()
+}
+
+// observeProvisioning : outcome la trang thai provision vua dat toi hoac "retry"
+func (m *metricsRegistry) observeProvisioning(objectName string, outcome string) {
+	key := provisionKey{deviceLabels: labelsOf(objectName), outcome: outcome}
+	m.mutex.Lock()
+	m.provisioning[key]++
+	m.mutex.Unlock()
+}
+
+// forget : bo cac series cua thiet bi da bi xoa
+func (m *metricsRegistry) forget(objectName string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for k := range m.latency {
+		if k.device == objectName {
+			delete(m.latency, k)
+		}
+	}
+	for k := range m.commands {
+		if k.device == objectName {
+			delete(m.commands, k)
+		}
+	}
+	for k := range m.pushEvents {
+		if k.device == objectName {
+			delete(m.pushEvents, k)
+		}
+	}
+	for k := range m.provisioning {
+		if k.device == objectName {
+			delete(m.provisioning, k)
+		}
+	}
+}
+
+// metricsHandler : GET metricsRoute
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	var b bytes.Buffer
+	metrics.write(&b)
+	writeLinkMetrics(&b)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(b.Bytes())
+}
+
+func (m *metricsRegistry) write(b *bytes.Buffer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	writeHeader(b, "zigbee_command_duration_seconds", "histogram", "Thoi gian tu khi nhan lenh doc/ghi den khi co ket qua.")
+	latencyKeys := make([]commandKey, 0, len(m.latency))
+	for k := range m.latency {
+		latencyKeys = append(latencyKeys, k)
+	}
+	sort.Slice(latencyKeys, func(i, j int) bool { return commandKeyLess(latencyKeys[i], latencyKeys[j]) })
+	for _, k := range latencyKeys {
+		h := m.latency[k]
+		labels := commandLabels(k)
+		var cumulative uint64
+		for i, upper := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(b, "zigbee_command_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(upper, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(b, "zigbee_command_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(b, "zigbee_command_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(b, "zigbee_command_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	writeHeader(b, "zigbee_commands_total", "counter", "So lenh doc/ghi theo status ZCL (hoac LINK_DOWN, NO_RESPONSE, ERROR).")
+	commandKeys := make([]commandStatusKey, 0, len(m.commands))
+	for k := range m.commands {
+		commandKeys = append(commandKeys, k)
+	}
+	sort.Slice(commandKeys, func(i, j int) bool {
+		if commandKeys[i].commandKey != commandKeys[j].commandKey {
+			return commandKeyLess(commandKeys[i].commandKey, commandKeys[j].commandKey)
+		}
+		return commandKeys[i].status < commandKeys[j].status
+	})
+	for _, k := range commandKeys {
+		fmt.Fprintf(b, "zigbee_commands_total{%s,status=\"%s\"} %d\n",
+			commandLabels(k.commandKey), escapeLabel(k.status), m.commands[k])
+	}
+
+	writeHeader(b, "zigbee_push_events_total", "counter", "So PushEvent nhan duoc tu thiet bi.")
+	pushKeys := make([]deviceLabels, 0, len(m.pushEvents))
+	for k := range m.pushEvents {
+		pushKeys = append(pushKeys, k)
+	}
+	sort.Slice(pushKeys, func(i, j int) bool { return deviceLabelsLess(pushKeys[i], pushKeys[j]) })
+	for _, k := range pushKeys {
+		fmt.Fprintf(b, "zigbee_push_events_total{%s} %d\n", formatDeviceLabels(k), m.pushEvents[k])
+	}
+
+	writeHeader(b, "zigbee_provisioning_total", "counter", "Ket qua cac buoc provision: joined, interviewed, failed, retry.")
+	provisionKeys := make([]provisionKey, 0, len(m.provisioning))
+	for k := range m.provisioning {
+		provisionKeys = append(provisionKeys, k)
+	}
+	sort.Slice(provisionKeys, func(i, j int) bool {
+		if provisionKeys[i].deviceLabels != provisionKeys[j].deviceLabels {
+			return deviceLabelsLess(provisionKeys[i].deviceLabels, provisionKeys[j].deviceLabels)
+		}
+		return provisionKeys[i].outcome < provisionKeys[j].outcome
+	})
+	for _, k := range provisionKeys {
+		fmt.Fprintf(b, "zigbee_provisioning_total{%s,outcome=\"%s\"} %d\n",
+			formatDeviceLabels(k.deviceLabels), escapeLabel(k.outcome), m.provisioning[k])
+	}
+}
+
+// writeLinkMetrics : trang thai ket noi, hang doi va LinkStats
+func writeLinkMetrics(b *bytes.Buffer) {
+	state := CurrentLinkState()
+	writeHeader(b, "zigbee_link_up", "gauge", "1 neu ket noi toi coordinator dang hoat dong.")
+	up := 0
+	if state == LinkUp {
+		up = 1
+	}
+	fmt.Fprintf(b, "zigbee_link_up %d\n", up)
+	writeHeader(b, "zigbee_link_state", "gauge", "Trang thai ket noi toi coordinator.")
+	for _, s := range []LinkState{LinkDown, LinkUp, LinkClosed} {
+		v := 0
+		if s == state {
+			v = 1
+		}
+		fmt.Fprintf(b, "zigbee_link_state{state=\"%s\"} %d\n", s.String(), v)
+	}
+
+	active, queued := txWin().usage()
+	writeHeader(b, "zigbee_pending_requests", "gauge", "So yeu cau dang cho phan hoi.")
+	fmt.Fprintf(b, "zigbee_pending_requests %d\n", pendingRequests().size())
+	writeHeader(b, "zigbee_tx_window_active", "gauge", "So cho dang dung trong cua so truyen.")
+	fmt.Fprintf(b, "zigbee_tx_window_active %d\n", active)
+	writeHeader(b, "zigbee_tx_window_queued", "gauge", "So yeu cau dang xep hang cho cua so truyen.")
+	fmt.Fprintf(b, "zigbee_tx_window_queued %d\n", queued)
+
+	s := linkStats()
+	items := []struct {
+		name string
+		help string
+		val  uint64
+	}{
+		{"zigbee_link_frames_sent_total", "So frame da gui.", s.FramesSent},
+		{"zigbee_link_frames_received_total", "So frame hop le da nhan.", s.FramesReceived},
+		{"zigbee_link_bytes_sent_total", "So byte da gui.", s.BytesSent},
+		{"zigbee_link_bytes_received_total", "So byte da nhan.", s.BytesReceived},
+		{"zigbee_link_crc_errors_total", "So frame sai CRC.", s.CRCErrors},
+		{"zigbee_link_length_errors_total", "So frame co do dai khong hop le.", s.LengthErrors},
+		{"zigbee_link_short_frames_total", "So frame bi cat giua chung.", s.ShortFrames},
+		{"zigbee_link_resyncs_total", "So lan phai bo byte de tim lai Header.", s.Resyncs},
+		{"zigbee_link_discarded_bytes_total", "So byte bi bo khi tim Header.", s.DiscardedBytes},
+		{"zigbee_link_unknown_cmd_total", "So frame co cmd khong ho tro.", s.UnknownCmd},
+		{"zigbee_link_json_errors_total", "So frame co payload JSON loi.", s.JSONErrors},
+		{"zigbee_link_unknown_address_total", "So frame tu dia chi khong co trong cache.", s.UnknownAddress},
+		{"zigbee_link_unmatched_frames_total", "So phan hoi khong con yeu cau nao cho.", s.UnmatchedFrames},
+		{"zigbee_link_address_changes_total", "So lan thiet bi tham gia lai mang voi dia chi ngan moi.", s.AddressChanges},
+		{"zigbee_link_response_timeouts_total", "So yeu cau het thoi gian cho phan hoi.", s.ResponseTimeouts},
+		{"zigbee_link_failures_total", "So lan mat ket noi toi coordinator.", s.LinkFailures},
+	}
+	for _, c := range items {
+		writeHeader(b, c.name, "counter", c.help)
+		fmt.Fprintf(b, "%s %d\n", c.name, c.val)
+	}
+	writeHeader(b, "zigbee_link_round_trip_avg_seconds", "gauge", "Thoi gian trung binh tu khi gui den khi nhan phan hoi.")
+	fmt.Fprintf(b, "zigbee_link_round_trip_avg_seconds %s\n", formatFloat(s.AvgRoundTripMs/1000))
+}
+
+func writeHeader(b *bytes.Buffer, name string, typ string, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func commandLabels(k commandKey) string {
+	return fmt.Sprintf("%s,operation=\"%s\"", formatDeviceLabels(k.deviceLabels), escapeLabel(k.operation))
+}
+
+func formatDeviceLabels(l deviceLabels) string {
+	return fmt.Sprintf("device=\"%s\",profile=\"%s\"", escapeLabel(l.device), escapeLabel(l.profile))
+}
+
+func deviceLabelsLess(a deviceLabels, b deviceLabels) bool {
+	if a.device != b.device {
+		return a.device < b.device
+	}
+	return a.profile < b.profile
+}
+
+func commandKeyLess(a commandKey, b commandKey) bool {
+	if a.deviceLabels != b.deviceLabels {
+		return deviceLabelsLess(a.deviceLabels, b.deviceLabels)
+	}
+	return a.operation < b.operation
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -1,79 +1,153 @@
 package packet
 
 import (
+	"context"
 	"strconv"
 	"sync"
-	"time"
 )
 
 const (
 	prefixRepoNameWithID  = "_id_"
 	prefixRepoNameWithMAC = "_mac_"
 	prefixRepoNameWithCMD = "_cmd_"
+	prefixRepoNameWithSeq = "_seq_"
 )
 
 var once sync.Once
 var rp *repoStruct
 
-type ContentRepoStruct struct {
-	Packet interface{} `json:"content"`
-	Cmd    int8        `json:"cmd"`
+// Waiter : cho phan hoi cua mot yeu cau tren kenh rieng
+type Waiter interface {
+	// Wait : cho phan hoi den khi ctx het han hoac bi huy
+	Wait(ctx context.Context) (interface{}, error)
+	// Cancel : huy dang ky, phan hoi den sau se khong duoc gui toi waiter nay
+	Cancel()
+}
+
+type waiterResult struct {
+	content interface{}
+	err     error
+}
+
+type waiter struct {
+	r        *repoStruct
+	nameRepo string
+	ch       chan waiterResult
 }
 
 type repoStruct struct {
-	repo sync.Map
+	mutex   sync.Mutex
+	waiters map[string][]*waiter
 }
 
 type RepoInterface interface {
-	SendToRepo(nameRepo string, content interface{})
-	GetFromRepo(nameRepo string) (interface{}, bool)
-	GetFromRepoAfterResetWithTime(nameRepo string, countRetry int32, timeMsStep int32) (interface{}, bool)
-	ResetRepo(nameRepo string)
+	Register(nameRepo string) Waiter
+	SendToRepo(nameRepo string, content interface{}) bool
+	HasWaiter(nameRepo string) bool
+	FailAll(err error)
 	GetRepoNameByID(id string) string
 	GetRepoNameByMAC(mac int64) string
 	GetRepoNameByCMD(cmd int8) string
+	GetRepoNameBySeq(seq uint8) string
 }
 
 func Repo() RepoInterface {
 	if rp == nil {
 		once.Do(func() {
-			rp = new(repoStruct)
+			rp = &repoStruct{
+				waiters: make(map[string][]*waiter),
+			}
 		})
 	}
 	return rp
 }
 
-func (r *repoStruct) SendToRepo(nameRepo string, content interface{}) {
-	r.repo.LoadOrStore(nameRepo, content)
+// Register : dang ky waiter cho nameRepo, cac waiter cung ten nhan phan hoi theo thu tu dang ky
+func (r *repoStruct) Register(nameRepo string) Waiter {
+	w := &waiter{
+		r:        r,
+		nameRepo: nameRepo,
+		ch:       make(chan waiterResult, 1),
+	}
+	r.mutex.Lock()
+	r.waiters[nameRepo] = append(r.waiters[nameRepo], w)
+	r.mutex.Unlock()
+	return w
 }
 
-func (r *repoStruct) GetFromRepo(nameRepo string) (interface{}, bool) {
-	c, ok := r.repo.Load(nameRepo)
-	if c == nil {
-		ok = false
+// SendToRepo : gui content toi waiter cu nhat cua nameRepo, false neu khong co waiter nao
+func (r *repoStruct) SendToRepo(nameRepo string, content interface{}) bool {
+	r.mutex.Lock()
+	ws := r.waiters[nameRepo]
+	if len(ws) == 0 {
+		r.mutex.Unlock()
+		return false
 	}
-	return c, ok
+	w := ws[0]
+	r.removeWithoutSync(w)
+	r.mutex.Unlock()
+
+	w.ch <- waiterResult{content: content}
+	return true
+}
+
+// HasWaiter : true neu co waiter dang cho nameRepo
+func (r *repoStruct) HasWaiter(nameRepo string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.waiters[nameRepo]) > 0
 }
 
-func (r *repoStruct) GetFromRepoAfterResetWithTime(nameRepo string, countRetry int32, timeMsStep int32) (result interface{}, ok bool) {
-	ok = false
-	if countRetry < 0 || timeMsStep < 0 {
-		return nil, false
+// FailAll : ket thuc tat ca waiter dang cho voi loi err
+func (r *repoStruct) FailAll(err error) {
+	r.mutex.Lock()
+	all := r.waiters
+	r.waiters = make(map[string][]*waiter)
+	r.mutex.Unlock()
+
+	for _, ws := range all {
+		for _, w := range ws {
+			w.ch <- waiterResult{err: err}
+		}
+	}
+}
+
+func (r *repoStruct) removeWithoutSync(w *waiter) bool {
+	ws := r.waiters[w.nameRepo]
+	for i, x := range ws {
+		if x == w {
+			ws = append(ws[:i], ws[i+1:]...)
+			if len(ws) == 0 {
+				delete(r.waiters, w.nameRepo)
+			} else {
+				r.waiters[w.nameRepo] = ws
+			}
+			return true
+		}
 	}
+	return false
+}
 
-	r.ResetRepo(nameRepo)
-	for ; countRetry >= 0; countRetry-- {
-		result, ok = r.GetFromRepo(nameRepo)
-		if ok {
-			break
+func (w *waiter) Wait(ctx context.Context) (interface{}, error) {
+	select {
+	case res := <-w.ch:
+		return res.content, res.err
+	case <-ctx.Done():
+		w.Cancel()
+		// phan hoi co the den dung luc het han
+		select {
+		case res := <-w.ch:
+			return res.content, res.err
+		default:
 		}
-		time.Sleep(time.Duration(timeMsStep) * time.Millisecond)
+		return nil, ctx.Err()
 	}
-	return
 }
 
-func (r *repoStruct) ResetRepo(nameRepo string) {
-	r.repo.Delete(nameRepo)
+func (w *waiter) Cancel() {
+	w.r.mutex.Lock()
+	w.r.removeWithoutSync(w)
+	w.r.mutex.Unlock()
 }
 
 func (r *repoStruct) GetRepoNameByID(id string) string {
@@ -87,3 +161,7 @@ func (r *repoStruct) GetRepoNameByMAC(mac int64) string {
 func (r *repoStruct) GetRepoNameByCMD(cmd int8) string {
 	return prefixRepoNameWithCMD + strconv.FormatInt(int64(cmd), 10)
 }
+
+func (r *repoStruct) GetRepoNameBySeq(seq uint8) string {
+	return prefixRepoNameWithSeq + strconv.FormatUint(uint64(seq), 10)
+}
@@ -0,0 +1,149 @@
+package driver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/device-zigbee/driver/packet"
+)
+
+// pendingRequest : yeu cau dang cho phan hoi
+type pendingRequest struct {
+	key      string // ten Repo theo ID/CMD, dung cho firmware khong tra lai seq
+	order    uint64
+	waiter   packet.Waiter
+	sent     time.Time // gui xong toi coordinator
+	answered time.Time // nhan duoc phan hoi
+}
+
+// pendingTable : bang cac yeu cau dang cho phan hoi, danh so theo seq (1-255)
+type pendingTable struct {
+	mutex   sync.Mutex
+	next    uint8
+	order   uint64
+	pending map[uint8]pendingRequest
+}
+
+var (
+	pendingOnce sync.Once
+	pt          *pendingTable
+)
+
+func pendingRequests() *pendingTable {
+	pendingOnce.Do(func() {
+		pt = &pendingTable{
+			pending: make(map[uint8]pendingRequest),
+		}
+	})
+	return pt
+}
+
+// add : cap seq cho yeu cau moi va dang ky waiter nhan phan hoi cua no
+func (p *pendingTable) add(key string) (seq uint8, waiter packet.Waiter, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i := 0; i < 255; i++ {
+		p.next++
+		if p.next == 0 {
+			p.next = 1
+		}
+		if _, used := p.pending[p.next]; !used {
+			seq = p.next
+			p.order++
+			waiter = packet.Repo().Register(packet.Repo().GetRepoNameBySeq(seq))
+			p.pending[seq] = pendingRequest{key: key, order: p.order, waiter: waiter}
+			return seq, waiter, nil
+		}
+	}
+	return 0, nil, ErrBusy
+}
+
+// done : giai phong seq khi yeu cau ket thuc
+func (p *pendingTable) done(seq uint8) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	r, ok := p.pending[seq]
+	if ok {
+		r.waiter.Cancel()
+		delete(p.pending, seq)
+		switch {
+		case r.sent.IsZero():
+		case !r.answered.IsZero():
+			counters.addRoundTrip(r.answered.Sub(r.sent))
+		case LinkIsUp():
+			// mat ket noi da duoc dem trong LinkFailures
+			counters.update(func(s *LinkStats) { s.ResponseTimeouts++ })
+		}
+	}
+}
+
+// markSent : ghi thoi diem yeu cau seq duoc giao cho transport, goi truoc khi ghi
+// de phan hoi den nhanh khong bi xu ly truoc. at rong = chua gui (ghi loi)
+func (p *pendingTable) markSent(seq uint8, at time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if r, ok := p.pending[seq]; ok {
+		r.sent = at
+		p.pending[seq] = r
+	}
+}
+
+// deliver : ghi thoi diem nhan phan hoi cua yeu cau seq roi gui content toi waiter cua no,
+// trong cung mutex voi done nen phan hoi da gui khong bi dem la het han
+func (p *pendingTable) deliver(seq uint8, content interface{}) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	r, ok := p.pending[seq]
+	if !ok || !r.answered.IsZero() {
+		return false
+	}
+	if !packet.Repo().SendToRepo(packet.Repo().GetRepoNameBySeq(seq), content) {
+		return false
+	}
+	r.answered = time.Now()
+	p.pending[seq] = r
+	return true
+}
+
+// lookupBySeq : true neu yeu cau co seq dang cho phan hoi
+func (p *pendingTable) lookupBySeq(seq uint8) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	r, ok := p.pending[seq]
+	return ok && r.answered.IsZero()
+}
+
+// lookupByKey : seq cua yeu cau cu nhat dang cho theo key (phan hoi khong co seq)
+func (p *pendingTable) lookupByKey(key string) (uint8, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var found bool
+	var seq uint8
+	var order uint64
+	for s, r := range p.pending {
+		if r.key != key {
+			continue
+		}
+		// bo qua yeu cau da nhan duoc phan hoi hoac da het han
+		if !r.answered.IsZero() || !packet.Repo().HasWaiter(packet.Repo().GetRepoNameBySeq(s)) {
+			continue
+		}
+		if !found || r.order < order {
+			found, seq, order = true, s, r.order
+		}
+	}
+	return seq, found
+}
+
+// size : so yeu cau dang cho phan hoi
+func (p *pendingTable) size() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.pending)
+}
@@ -1,13 +1,13 @@
 package driver
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
-	sdk "github.com/edgexfoundry/device-sdk-go"
 	dsModels "github.com/edgexfoundry/device-sdk-go/pkg/models"
 	sdkModel "github.com/edgexfoundry/device-sdk-go/pkg/models"
 	"github.com/edgexfoundry/go-mod-core-contracts/models"
@@ -41,7 +41,7 @@ func execWriteCmd(d *Driver, device contract.Device, cmd string, params string)
 		return nil, nil, err
 	}
 
-	service := sdk.RunningService()
+	service := runningService()
 
 	cvs, err := parseWriteParams(d, device, device.Profile.Name, ros, params)
 	if err != nil {
@@ -77,7 +77,7 @@ func execWriteCmd(d *Driver, device contract.Device, cmd string, params string)
 }
 
 func getResourceOperationsByCommand(device contract.Device, profileName string, cmd string, method string) ([]models.ResourceOperation, error) {
-	service := sdk.RunningService()
+	service := runningService()
 	sliceProfile := service.DeviceProfiles()
 	for _, pro := range sliceProfile {
 		if pro.Name == profileName {
@@ -107,7 +107,7 @@ func parseWriteParams(d *Driver, device contract.Device, profileName string, ros
 		return []*dsModels.CommandValue{}, err
 	}
 
-	service := sdk.RunningService()
+	service := runningService()
 
 	result := make([]*dsModels.CommandValue, 0, len(paramMap))
 	for _, ro := range ros {
@@ -169,7 +169,7 @@ func parseParams(d *Driver, params string) (paramMap map[string]string, err erro
 }
 
 func createCommandValueFromRO(d *Driver, device contract.Device, profileName string, ro *contract.ResourceOperation, v string) (*dsModels.CommandValue, error) {
-	service := sdk.RunningService()
+	service := runningService()
 	dr, ok := service.DeviceResource(device.Name, ro.DeviceResource, setCmdMethod)
 	if !ok {
 		msg := fmt.Sprintf("createCommandValueForParam: no deviceResource: %s", ro.DeviceResource)
@@ -194,6 +194,10 @@ func createCommandValueFromDR(d *Driver, dr *contract.DeviceResource, v string)
 	case "string":
 		value = v
 		t = dsModels.String
+	case "binary":
+		// tham so la chuoi hex
+		value, err = hex.DecodeString(v)
+		t = dsModels.Binary
 	case "uint8":
 		n, e := strconv.ParseUint(v, 10, 8)
 		value = uint8(n)
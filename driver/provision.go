@@ -0,0 +1,404 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/device-zigbee/driver/packet"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/models"
+)
+
+// trang thai provision luu trong protocol Provision cua thiet bi:
+//	pending     -> chua gui / cho gui lai yeu cau them doi tuong
+//	sent        -> da gui yeu cau them doi tuong, chua co phan hoi
+//	joined      -> coordinator da cap dia chi, chua hoi thong tin thiet bi
+//	interviewed -> thiet bi da tra loi, san sang su dung
+//	failed      -> het so lan thu lai, dat lai State = pending de thu lai
+const (
+	provisionPending     = "pending"
+	provisionSent        = "sent"
+	provisionJoined      = "joined"
+	provisionInterviewed = "interviewed"
+	provisionFailed      = "failed"
+)
+
+const (
+	nameProvisionProtocol = "Provision"
+	nameStateProperty     = "State"
+	nameAttemptsProperty  = "Attempts"
+	nameErrorProperty     = "Error"
+	nameUpdatedProperty   = "Updated"
+
+	// provisionStatusResource : resource chi doc tra ve trang thai provision dang JSON
+	provisionStatusResource = "ProvisionStatus"
+)
+
+// ten cac khoa cau hinh provision trong muc [Driver]
+const (
+	nameProvisionRetriesConfig       = "ProvisionRetries"       // so lan thu lai truoc khi chuyen sang failed
+	nameProvisionRetryIntervalConfig = "ProvisionRetryInterval" // thoi gian cho truoc lan thu lai dau tien (ms)
+)
+
+const (
+	defaultProvisionRetries       = 5
+	defaultProvisionRetryInterval = 10000 * time.Millisecond
+	maxProvisionRetryInterval     = 5 * time.Minute
+)
+
+// interviewAttInfo : ZCLVersion cua Basic cluster, thiet bi nao cung phai co
+var interviewAttInfo = AttributeInfo{
+	ProfileID:   260,
+	ClusterID:   0,
+	AttributeID: 0,
+	ValueType:   0x20,
+}
+
+type provisionConfig struct {
+	retries  int
+	interval time.Duration
+}
+
+var (
+	provisionConfigMutex sync.Mutex
+	provisionCfg         = provisionConfig{
+		retries:  defaultProvisionRetries,
+		interval: defaultProvisionRetryInterval,
+	}
+)
+
+// initProvisioning : doc cau hinh provision tu muc [Driver] cua configuration.toml
+func initProvisioning(config map[string]string) error {
+	c := provisionConfig{
+		retries:  defaultProvisionRetries,
+		interval: defaultProvisionRetryInterval,
+	}
+	if v, ok := configValue(config, nameProvisionRetriesConfig); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameProvisionRetriesConfig, v)
+		}
+		c.retries = n
+	}
+	if v, ok := configValue(config, nameProvisionRetryIntervalConfig); ok {
+		ms, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || ms == 0 {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameProvisionRetryIntervalConfig, v)
+		}
+		c.interval = time.Duration(ms) * time.Millisecond
+	}
+
+	provisionConfigMutex.Lock()
+	provisionCfg = c
+	provisionConfigMutex.Unlock()
+	return nil
+}
+
+func currentProvisioning() provisionConfig {
+	provisionConfigMutex.Lock()
+	defer provisionConfigMutex.Unlock()
+	return provisionCfg
+}
+
+// ProvisionStatus : trang thai provision cua 1 thiet bi
+type ProvisionStatus struct {
+	State    string `json:"state"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+	Updated  string `json:"updated,omitempty"`
+}
+
+// needsWork : trang thai chua ket thuc, can tiep tuc provision
+func (s ProvisionStatus) needsWork() bool {
+	return s.State != provisionInterviewed && s.State != provisionFailed
+}
+
+// provisionStatusOf : doc trang thai tu protocol Provision, thiet bi cu chi co nhan initializied
+func provisionStatusOf(device models.Device) ProvisionStatus {
+	pp, ok := device.Protocols[nameProvisionProtocol]
+	if !ok || pp[nameStateProperty] == "" {
+		if labelsType(device.Labels).isInitializied() {
+			return ProvisionStatus{State: provisionInterviewed}
+		}
+		return ProvisionStatus{State: provisionPending}
+	}
+	st := ProvisionStatus{
+		State:   pp[nameStateProperty],
+		Error:   pp[nameErrorProperty],
+		Updated: pp[nameUpdatedProperty],
+	}
+	st.Attempts, _ = strconv.Atoi(pp[nameAttemptsProperty])
+	return st
+}
+
+// setProvisionStatus : ghi trang thai vao protocol Provision va dong bo nhan initializied
+func setProvisionStatus(device *models.Device, st ProvisionStatus) {
+	st.Updated = time.Now().UTC().Format(time.RFC3339)
+	// khong sua map/slice dung chung voi cache cua SDK
+	protocols := make(map[string]models.ProtocolProperties, len(device.Protocols)+1)
+	for k, v := range device.Protocols {
+		protocols[k] = v
+	}
+	device.Protocols = protocols
+	device.Protocols[nameProvisionProtocol] = models.ProtocolProperties{
+		nameStateProperty:    st.State,
+		nameAttemptsProperty: strconv.Itoa(st.Attempts),
+		nameErrorProperty:    st.Error,
+		nameUpdatedProperty:  st.Updated,
+	}
+	if st.State == provisionInterviewed {
+		labels := append([]string(nil), device.Labels...)
+		device.Labels = labelsType(labels).setInitializied()
+	}
+}
+
+// provisioner : cac thiet bi dang duoc provision o nen, moi thiet bi 1 goroutine
+type provisioner struct {
+	mutex   sync.Mutex
+	running map[string]bool
+}
+
+var (
+	provisionerOnce sync.Once
+	pv              *provisioner
+)
+
+func provisioning() *provisioner {
+	provisionerOnce.Do(func() {
+		pv = &provisioner{
+			running: make(map[string]bool),
+		}
+	})
+	return pv
+}
+
+// start : bat dau provision thiet bi neu chua chay
+func (p *provisioner) start(d *Driver, device models.Device) {
+	p.mutex.Lock()
+	if p.running[device.Name] {
+		p.mutex.Unlock()
+		return
+	}
+	p.running[device.Name] = true
+	p.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			p.mutex.Lock()
+			delete(p.running, device.Name)
+			p.mutex.Unlock()
+		}()
+		d.runProvisioning(device)
+	}()
+}
+
+// resumeProvisioning : tiep tuc provision cac thiet bi chua xong truoc khi service khoi dong lai
+func (d *Driver) resumeProvisioning() {
+	for _, device := range runningService().Devices() {
+		if device.Profile.Name == managerProfileNameConst {
+			continue
+		}
+		if provisionStatusOf(device).needsWork() {
+			provisioning().start(d, device)
+		}
+	}
+}
+
+// runProvisioning : chay may trang thai cho den interviewed/failed, thu lai voi thoi gian cho tang dan.
+// Ban sao device duoc giu trong goroutine vi cache cua SDK chi cap nhat khi metadata goi lai.
+func (d *Driver) runProvisioning(device models.Device) {
+	cfg := currentProvisioning()
+	delay := cfg.interval
+	for {
+		if d.context().Err() != nil {
+			return
+		}
+		st := provisionStatusOf(device)
+		var err error
+		switch st.State {
+		case provisionInterviewed, provisionFailed:
+			return
+
+		case provisionJoined:
+			err = d.interviewObject(device)
+			if err == nil {
+				st = ProvisionStatus{State: provisionInterviewed}
+				setProvisionStatus(&device, st)
+				d.persistProvisioning(device)
+				metrics.observeProvisioning(device.Name, provisionInterviewed)
+				driver.Logger.Info(fmt.Sprintf("Provision %s: hoan tat", device.Name))
+				return
+			}
+
+		default:
+			st.State = provisionSent
+			setProvisionStatus(&device, st)
+			if !d.persistProvisioning(device) {
+				return
+			}
+			var joined models.Device
+			joined, err = d.provisionObject(device)
+			if err == nil {
+				device = joined
+				metrics.observeProvisioning(device.Name, provisionJoined)
+				setProvisionStatus(&device, ProvisionStatus{State: provisionJoined})
+				if !d.persistProvisioning(device) {
+					return
+				}
+				Cache().UpdateObject(device)
+				delay = cfg.interval
+				continue
+			}
+			st.State = provisionPending
+		}
+
+		st.Attempts++
+		st.Error = err.Error()
+		if st.Attempts > cfg.retries {
+			st.State = provisionFailed
+			metrics.observeProvisioning(device.Name, provisionFailed)
+		} else {
+			metrics.observeProvisioning(device.Name, "retry")
+		}
+		setProvisionStatus(&device, st)
+		if !d.persistProvisioning(device) || st.State == provisionFailed {
+			driver.Logger.Error(fmt.Sprintf("Provision %s that bai sau %d lan: %v", device.Name, st.Attempts, err))
+			return
+		}
+		driver.Logger.Warn(fmt.Sprintf("Provision %s (%s) lan %d that bai: %v, thu lai sau %v",
+			device.Name, st.State, st.Attempts, err, delay))
+
+		select {
+		case <-time.After(delay):
+		case <-d.context().Done():
+			return
+		}
+		delay *= 2
+		if delay > maxProvisionRetryInterval {
+			delay = maxProvisionRetryInterval
+		}
+	}
+}
+
+// persistProvisioning : luu thiet bi vao metadata, false neu thiet bi da bi xoa
+func (d *Driver) persistProvisioning(device models.Device) bool {
+	err := runningService().UpdateDevice(device)
+	if err != nil {
+		driver.Logger.Error(fmt.Sprintf("Provision %s: khong luu duoc trang thai: %v", device.Name, err))
+		return false
+	}
+	return true
+}
+
+// provisionObject : gui yeu cau them doi tuong, tra ve device da co dia chi mang
+func (d *Driver) provisionObject(device models.Device) (models.Device, error) {
+	var mac string
+	var pan uint16
+	pp, ok := device.Protocols[nameNetworkProtocol]
+	if !ok {
+		return device, fmt.Errorf("zigbee: device %s has no %s protocol", device.Name, nameNetworkProtocol)
+	}
+	mac = pp[nameMACProperty]
+	upan, _ := strconv.ParseUint(pp[namePANProperty], 10, 16)
+	pan = uint16(upan)
+
+	frame := ProvisonFrame{
+		AddressEUI64: AddressEUI64{
+			MAC: mac,
+			PAN: pan,
+		},
+		NameDevice: device.Name,
+	}
+	if labelsType(device.Labels).getType() != DEVICETYPE {
+		frame.MAC = "00000000"
+	}
+
+	ctx, cancel := d.backgroundContext()
+	defer cancel()
+	response, err := sendAndAwait(ctx, request{
+		cmd: AddObjectCmdConst,
+		key: packet.Repo().GetRepoNameByCMD(AddObjectCmdConst),
+		frame: func(seq uint8) interface{} {
+			frame.Seq = seq
+			return frame
+		},
+		dest:    "mac:" + frame.MAC,
+		timeout: currentTimeouts().provision,
+	})
+	if err != nil {
+		return device, err
+	}
+
+	objectInfo := response.ObjectInfo
+	nw := make(models.ProtocolProperties, len(pp)+5)
+	for k, v := range pp {
+		nw[k] = v
+	}
+	nw[nameMACProperty] = objectInfo.MAC
+	nw[namePANProperty] = strconv.FormatUint(uint64(objectInfo.PAN), 10)
+	nw[nameAddressProperty] = strconv.FormatUint(uint64(objectInfo.Address), 10)
+	nw[nameEndpointProperty] = strconv.FormatUint(uint64(objectInfo.Endpoint), 10)
+	nw[nameTypeProperty] = strconv.FormatUint(uint64(objectInfo.Type), 10)
+
+	protocols := make(map[string]models.ProtocolProperties, len(device.Protocols))
+	for k, v := range device.Protocols {
+		protocols[k] = v
+	}
+	protocols[nameNetworkProtocol] = nw
+	device.Protocols = protocols
+
+	if response.Description != "" {
+		device.Description = response.Description
+	}
+	return device, nil
+}
+
+// interviewObject : hoi ZCLVersion cua thiet bi de chac chan thiet bi da tham gia mang va tra loi
+func (d *Driver) interviewObject(device models.Device) error {
+	if labelsType(device.Labels).getType() != DEVICETYPE {
+		// group/scenario khong co thiet bi vat ly de hoi
+		return nil
+	}
+	addr, ok := getObjectAddressFromProtocol(device.Protocols)
+	if !ok {
+		return ErrObjectNoAddress
+	}
+	idObject := device.Id
+	if id, ok := Cache().ConvertAddrToIDObject(addr); ok {
+		idObject = id
+	}
+
+	ctx, cancel := d.backgroundContext()
+	defer cancel()
+	_, err := sendAndAwait(ctx, request{
+		cmd: CommandCmdConst,
+		key: packet.Repo().GetRepoNameByID(idObject),
+		frame: func(seq uint8) interface{} {
+			return CommandFrame{
+				ObjectAddress: addr,
+				Seq:           seq,
+				CommandID:     CommandIDRead,
+				AttributeInfo: interviewAttInfo,
+			}
+		},
+		dest:    destinationOfAddress(addr),
+		timeout: responseTimeoutOf(device.Protocols),
+	})
+	return err
+}
+
+// provisionStatusValue : gia tri cua resource ProvisionStatus
+func provisionStatusValue(deviceName string) (string, error) {
+	device, err := runningService().GetDeviceByName(deviceName)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(provisionStatusOf(device))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,257 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/clients"
+	"github.com/edgexfoundry/go-mod-core-contracts/clients/metadata"
+	"github.com/edgexfoundry/go-mod-core-contracts/clients/types"
+	"github.com/edgexfoundry/go-mod-core-contracts/models"
+)
+
+// ten khoa cau hinh doi chieu cache trong muc [Driver]
+const (
+	nameReconcileIntervalConfig = "ReconcileInterval" // chu ky doi chieu cache voi core-metadata (ms), 0 = tat
+)
+
+const (
+	defaultReconcileInterval = 10 * time.Minute
+	// reconcileTimeout : thoi gian toi da lay danh sach thiet bi va profile tu core-metadata
+	reconcileTimeout = 30 * time.Second
+)
+
+var (
+	reconcileMutex    sync.Mutex
+	reconcileInterval = defaultReconcileInterval
+	deviceClient      metadata.DeviceClient
+	profileClient     metadata.DeviceProfileClient
+)
+
+// reconcileRunMutex : moi lan chi 1 lan doi chieu (chu ky va lenh Reconcile)
+var reconcileRunMutex sync.Mutex
+
+// initReconcile : doc cau hinh doi chieu cache tu muc [Driver] cua configuration.toml,
+// metadataURL la dia chi core-metadata theo [Clients.Metadata] cua SDK
+func initReconcile(config map[string]string, metadataURL string) error {
+	interval := defaultReconcileInterval
+	if v, ok := configValue(config, nameReconcileIntervalConfig); ok {
+		ms, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameReconcileIntervalConfig, v)
+		}
+		interval = time.Duration(ms) * time.Millisecond
+	}
+	metadataURL = strings.TrimSuffix(metadataURL, "/")
+
+	reconcileMutex.Lock()
+	reconcileInterval = interval
+	deviceClient = metadata.NewDeviceClient(types.EndpointParams{
+		Path: clients.ApiDeviceRoute,
+		Url:  metadataURL + clients.ApiDeviceRoute,
+	}, nil)
+	profileClient = metadata.NewDeviceProfileClient(types.EndpointParams{
+		Path: clients.ApiDeviceProfileRoute,
+		Url:  metadataURL + clients.ApiDeviceProfileRoute,
+	}, nil)
+	reconcileMutex.Unlock()
+	return nil
+}
+
+func metadataClients() (metadata.DeviceClient, metadata.DeviceProfileClient) {
+	reconcileMutex.Lock()
+	defer reconcileMutex.Unlock()
+	return deviceClient, profileClient
+}
+
+func currentReconcileInterval() time.Duration {
+	reconcileMutex.Lock()
+	defer reconcileMutex.Unlock()
+	return reconcileInterval
+}
+
+// cacheDiff : khac biet giua cache cu va cache dung lai tu metadata
+type cacheDiff struct {
+	AddedObjects     []string // ten doi tuong
+	RemovedObjects   []string
+	RenamedObjects   []string // "cu -> moi"
+	StaleAddresses   []string // dia chi khong con tro toi doi tuong cu
+	AddedResources   []string // "profile/resource"
+	RemovedResources []string
+	ChangedResources []string
+}
+
+func (c cacheDiff) empty() bool {
+	return len(c.AddedObjects)+len(c.RemovedObjects)+len(c.RenamedObjects)+len(c.StaleAddresses)+
+		len(c.AddedResources)+len(c.RemovedResources)+len(c.ChangedResources) == 0
+}
+
+func (c cacheDiff) String() string {
+	if c.empty() {
+		return "no change"
+	}
+	var parts []string
+	add := func(label string, items []string) {
+		if len(items) > 0 {
+			parts = append(parts, fmt.Sprintf("%s [%s]", label, strings.Join(items, ", ")))
+		}
+	}
+	add("added objects", c.AddedObjects)
+	add("removed objects", c.RemovedObjects)
+	add("renamed objects", c.RenamedObjects)
+	add("stale addresses", c.StaleAddresses)
+	add("added resources", c.AddedResources)
+	add("removed resources", c.RemovedResources)
+	add("changed resources", c.ChangedResources)
+	return strings.Join(parts, "; ")
+}
+
+// reconcileCache : dung lai moi chi muc cua cache tu danh sach thiet bi va profile lay truc tiep
+// tu core-metadata (ban sao trong SDK duoc cap nhat cung callback voi cache nen khong phat hien
+// duoc callback bi mat), thay cache hien tai va tra ve khac biet
+func reconcileCache(ctx context.Context) (cacheDiff, error) {
+	reconcileRunMutex.Lock()
+	defer reconcileRunMutex.Unlock()
+
+	// dia chi doi do rejoin trong luc lay du lieu duoc ap dung lai len du lieu moi
+	rejoinMutex.Lock()
+	pendingAddresses = make(map[string]uint16)
+	rejoinMutex.Unlock()
+
+	devices, profiles, err := fetchMetadata(ctx, runningService().Name())
+
+	rejoinMutex.Lock()
+	defer rejoinMutex.Unlock()
+	pending := pendingAddresses
+	pendingAddresses = nil
+	if err != nil {
+		return cacheDiff{}, err
+	}
+	fresh := newObjectCache(withProfiles(devices, profiles))
+	for id, address := range pending {
+		fresh.UpdateAddress(id, address)
+	}
+	return Cache().(*objectCache).replace(fresh), nil
+}
+
+// fetchMetadata : thiet bi cua device service serviceName va moi profile tu core-metadata
+func fetchMetadata(ctx context.Context, serviceName string) ([]models.Device, []models.DeviceProfile, error) {
+	dc, pc := metadataClients()
+	if dc == nil || pc == nil {
+		return nil, nil, fmt.Errorf("zigbee: metadata client is not initialized")
+	}
+	devices, err := dc.DevicesForServiceByName(serviceName, ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("zigbee: cannot list devices from core-metadata: %v", err)
+	}
+	profiles, err := pc.DeviceProfiles(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("zigbee: cannot list device profiles from core-metadata: %v", err)
+	}
+	return devices, profiles, nil
+}
+
+// withProfiles : profile kem theo thiet bi co the cu, lay profile moi nhat theo ten
+func withProfiles(ds []models.Device, profiles []models.DeviceProfile) []models.Device {
+	byName := make(map[string]models.DeviceProfile, len(profiles))
+	for _, p := range profiles {
+		byName[p.Name] = p
+	}
+	for i := range ds {
+		if p, ok := byName[ds[i].Profile.Name]; ok {
+			ds[i].Profile = p
+		}
+	}
+	return ds
+}
+
+// replace : thay cac chi muc bang chi muc cua fresh, tra ve khac biet
+func (oc *objectCache) replace(fresh *objectCache) cacheDiff {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+
+	var diff cacheDiff
+	for id, name := range fresh.idNameObject {
+		old, ok := oc.idNameObject[id]
+		switch {
+		case !ok:
+			diff.AddedObjects = append(diff.AddedObjects, name)
+		case old != name:
+			diff.RenamedObjects = append(diff.RenamedObjects, old+" -> "+name)
+		}
+	}
+	for id, name := range oc.idNameObject {
+		if _, ok := fresh.idNameObject[id]; !ok {
+			diff.RemovedObjects = append(diff.RemovedObjects, name)
+		}
+	}
+	for addr, id := range oc.addrIDObjectMap {
+		if fresh.addrIDObjectMap[addr] != id {
+			diff.StaleAddresses = append(diff.StaleAddresses, fmt.Sprintf("%+v (%s)", addr, oc.idNameObject[id]))
+		}
+	}
+	for k, att := range fresh.resAttMap {
+		old, ok := oc.resAttMap[k]
+		switch {
+		case !ok:
+			diff.AddedResources = append(diff.AddedResources, k.profile+"/"+k.resource)
+		case old != att:
+			diff.ChangedResources = append(diff.ChangedResources, k.profile+"/"+k.resource)
+		}
+	}
+	for k := range oc.resAttMap {
+		if _, ok := fresh.resAttMap[k]; !ok {
+			diff.RemovedResources = append(diff.RemovedResources, k.profile+"/"+k.resource)
+		}
+	}
+	for _, s := range [][]string{diff.AddedObjects, diff.RemovedObjects, diff.RenamedObjects, diff.StaleAddresses,
+		diff.AddedResources, diff.RemovedResources, diff.ChangedResources} {
+		sort.Strings(s)
+	}
+
+	oc.nameIDObject = fresh.nameIDObject
+	oc.idNameObject = fresh.idNameObject
+	oc.resAttMap = fresh.resAttMap
+	oc.attResMap = fresh.attResMap
+	oc.addrIDObjectMap = fresh.addrIDObjectMap
+	oc.idInfoObjectMap = fresh.idInfoObjectMap
+	oc.macIDObjectMap = fresh.macIDObjectMap
+	oc.nameProfileMap = fresh.nameProfileMap
+	oc.nameMasterDevice = fresh.nameMasterDevice
+	return diff
+}
+
+// reconcile : doi chieu cache va ghi log khac biet. Loi khi lay du lieu tu core-metadata giu nguyen cache
+func (d *Driver) reconcile() (cacheDiff, error) {
+	ctx, cancel := context.WithTimeout(d.context(), reconcileTimeout)
+	defer cancel()
+	diff, err := reconcileCache(ctx)
+	switch {
+	case err != nil:
+		d.Logger.Error(fmt.Sprintf("Cache reconcile failed: %v", err))
+	case diff.empty():
+		d.Logger.Debug("Cache reconcile: no change")
+	default:
+		d.Logger.Info(fmt.Sprintf("Cache reconcile: %s", diff))
+	}
+	return diff, err
+}
+
+// reconcileLoop : doi chieu cache dinh ky cho den khi driver dung
+func (d *Driver) reconcileLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.context().Done():
+			return
+		case <-ticker.C:
+			d.reconcile()
+		}
+	}
+}
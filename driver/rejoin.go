@@ -0,0 +1,92 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/models"
+)
+
+var (
+	// rejoinMutex : cac device-announce cua cung thiet bi duoc xu ly lan luot
+	rejoinMutex sync.Mutex
+	// pendingAddresses : dia chi moi theo ID doi tuong trong luc doi chieu cache (reconcileCache),
+	// nil khi khong doi chieu
+	pendingAddresses map[string]uint16
+)
+
+// updateShortAddress : thiet bi MAC tham gia lai mang voi dia chi ngan moi.
+// Moi doi tuong (endpoint) da provision cua thiet bi duoc cap nhat trong cache
+// va trong protocol Network o metadata
+func updateShortAddress(mac string, address uint16) {
+	rejoinMutex.Lock()
+	defer rejoinMutex.Unlock()
+
+	for _, id := range Cache().ConvertMACToIDObjects(mac) {
+		info, ok := Cache().ConvertIDToObjectInfo(id)
+		if !ok || info.Address == address {
+			// chua provision (provision se ghi dia chi) hoac dia chi khong doi
+			continue
+		}
+		name, ok := Cache().ConvertIDToNameObject(id)
+		if !ok {
+			continue
+		}
+		device, err := runningService().GetDeviceByName(name)
+		if err != nil {
+			driver.Logger.Warn(fmt.Sprintf("Rejoin %s: %v", name, err))
+			continue
+		}
+		device.Protocols = withAddress(device.Protocols, address)
+
+		// cache cap nhat ngay de frame tu dia chi moi khong bi bo, metadata goi lai UpdateDevice sau.
+		// Device trong cache cua SDK giu profile luc them vao, chi doi dia chi
+		if !Cache().UpdateAddress(id, address) {
+			continue
+		}
+		if pendingAddresses != nil {
+			pendingAddresses[id] = address
+		}
+		counters.update(func(s *LinkStats) { s.AddressChanges++ })
+		driver.Logger.Info(fmt.Sprintf("Rejoin %s: MAC=%s Address %d -> %d", name, mac, info.Address, address))
+		err = runningService().UpdateDevice(device)
+		if err != nil {
+			driver.Logger.Error(fmt.Sprintf("Rejoin %s: khong luu duoc dia chi moi: %v", name, err))
+		}
+	}
+}
+
+// withAddress : ban sao protocols voi Address moi trong protocol Network,
+// khong sua map dung chung voi cache cua SDK
+func withAddress(protocols map[string]models.ProtocolProperties, address uint16) map[string]models.ProtocolProperties {
+	result := make(map[string]models.ProtocolProperties, len(protocols))
+	for name, pp := range protocols {
+		result[name] = pp
+	}
+	network := make(models.ProtocolProperties, len(protocols[nameNetworkProtocol])+1)
+	for k, v := range protocols[nameNetworkProtocol] {
+		network[k] = v
+	}
+	network[nameAddressProperty] = strconv.FormatUint(uint64(address), 10)
+	result[nameNetworkProtocol] = network
+	return result
+}
+
+// objectOfFrame : doi tuong gui frame. MAC (neu firmware gui kem) la dinh danh chinh,
+// dia chi ngan khac cache nghia la thiet bi da tham gia lai mang ma chua co device-announce
+func objectOfFrame(info ObjectInfo) (string, bool) {
+	if info.MAC != "" {
+		for _, id := range Cache().ConvertMACToIDObjects(info.MAC) {
+			cached, ok := Cache().ConvertIDToObjectInfo(id)
+			if !ok || cached.Type != info.Type || cached.Endpoint != info.Endpoint {
+				continue
+			}
+			if cached.Address != info.Address {
+				updateShortAddress(info.MAC, info.Address)
+			}
+			return id, true
+		}
+	}
+	return Cache().ConvertAddrToIDObject(info.ObjectAddress)
+}
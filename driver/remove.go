@@ -0,0 +1,196 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/device-zigbee/driver/packet"
+)
+
+// ten cac khoa cau hinh xoa doi tuong trong muc [Driver]
+const (
+	nameRemoveLeaveConfig         = "RemoveLeave"         // gui Zigbee leave request khi xoa thiet bi
+	nameRemoveRetriesConfig       = "RemoveRetries"       // so lan thu lai khi thiet bi/coordinator khong phan hoi
+	nameRemoveRetryIntervalConfig = "RemoveRetryInterval" // thoi gian cho truoc lan thu lai dau tien (ms)
+)
+
+const (
+	defaultRemoveRetries       = 5
+	defaultRemoveRetryInterval = 30000 * time.Millisecond
+	maxRemoveRetryInterval     = 10 * time.Minute
+)
+
+type removalConfig struct {
+	leave    bool
+	retries  int
+	interval time.Duration
+}
+
+var (
+	removalMutex sync.Mutex
+	removal      = removalConfig{
+		leave:    true,
+		retries:  defaultRemoveRetries,
+		interval: defaultRemoveRetryInterval,
+	}
+)
+
+// initRemoval : doc cau hinh xoa doi tuong tu muc [Driver] cua configuration.toml
+func initRemoval(config map[string]string) error {
+	r := removalConfig{
+		leave:    true,
+		retries:  defaultRemoveRetries,
+		interval: defaultRemoveRetryInterval,
+	}
+	if v, ok := configValue(config, nameRemoveLeaveConfig); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameRemoveLeaveConfig, v)
+		}
+		r.leave = b
+	}
+	if v, ok := configValue(config, nameRemoveRetriesConfig); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameRemoveRetriesConfig, v)
+		}
+		r.retries = n
+	}
+	if v, ok := configValue(config, nameRemoveRetryIntervalConfig); ok {
+		ms, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || ms == 0 {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameRemoveRetryIntervalConfig, v)
+		}
+		r.interval = time.Duration(ms) * time.Millisecond
+	}
+
+	removalMutex.Lock()
+	removal = r
+	removalMutex.Unlock()
+	return nil
+}
+
+func currentRemoval() removalConfig {
+	removalMutex.Lock()
+	defer removalMutex.Unlock()
+	return removal
+}
+
+// removeObject : xoa doi tuong khoi mang Zigbee, neu khong phan hoi thi thu lai o nen
+func (d *Driver) removeObject(objectName string, addr ObjectAddress) error {
+	cfg := currentRemoval()
+	frame := DeleteObjectFrame{
+		ObjectAddress: addr,
+		Leave:         cfg.leave,
+	}
+
+	ctx, cancel := d.requestContext()
+	err := d.sendDeleteObject(ctx, frame)
+	cancel()
+	if err == nil {
+		driver.Logger.Info(fmt.Sprintf("Da xoa %s (Address=%d) khoi mang", objectName, addr.Address))
+		return nil
+	}
+	if _, refused := err.(ZCLStatusError); refused || cfg.retries == 0 {
+		driver.Logger.Error(fmt.Sprintf("Khong xoa duoc %s khoi mang: %v", objectName, err))
+		return fmt.Errorf("zigbee: cannot remove %s from the network: %v", objectName, err)
+	}
+
+	driver.Logger.Warn(fmt.Sprintf("Khong xoa duoc %s khoi mang: %v, thu lai %d lan, lan dau sau %v",
+		objectName, err, cfg.retries, cfg.interval))
+	go d.retryRemoveObject(objectName, pendingRemoval{Frame: frame}, cfg)
+	return fmt.Errorf("zigbee: %s did not respond, removal will be retried in %v", objectName, cfg.interval)
+}
+
+// retryRemoveObject : thu xoa lai voi thoi gian cho tang dan. Yeu cau duoc luu trong kho trang thai
+// de tiep tuc sau khi khoi dong lai (resumeRemovals)
+func (d *Driver) retryRemoveObject(objectName string, r pendingRemoval, cfg removalConfig) {
+	frame := r.Frame
+	saveRemoval(objectName, r)
+	delay := cfg.interval
+	// tiep tuc sau khi khoi dong lai: thoi gian cho cua lan thu ke tiep
+	for i := 0; i < r.Attempt && delay < maxRemoveRetryInterval; i++ {
+		delay *= 2
+	}
+	if delay > maxRemoveRetryInterval {
+		delay = maxRemoveRetryInterval
+	}
+	for attempt := r.Attempt + 1; attempt <= cfg.retries; attempt++ {
+		select {
+		case <-time.After(delay):
+		case <-d.context().Done():
+			// giu yeu cau trong kho, tiep tuc o lan khoi dong sau
+			return
+		}
+		// dia chi da duoc cap lai cho doi tuong khac
+		if _, used := Cache().ConvertAddrToIDObject(frame.ObjectAddress); used {
+			driver.Logger.Info(fmt.Sprintf("Huy xoa %s: dia chi %d da duoc dung lai", objectName, frame.Address))
+			forgetRemoval(objectName)
+			return
+		}
+
+		ctx, cancel := d.backgroundContext()
+		err := d.sendDeleteObject(ctx, frame)
+		cancel()
+		if err == nil {
+			driver.Logger.Info(fmt.Sprintf("Da xoa %s (Address=%d) khoi mang sau %d lan thu lai",
+				objectName, frame.Address, attempt))
+			forgetRemoval(objectName)
+			return
+		}
+		if _, refused := err.(ZCLStatusError); refused {
+			driver.Logger.Error(fmt.Sprintf("Khong xoa duoc %s khoi mang: %v", objectName, err))
+			forgetRemoval(objectName)
+			return
+		}
+		driver.Logger.Warn(fmt.Sprintf("Thu xoa %s lan %d/%d that bai: %v", objectName, attempt, cfg.retries, err))
+		saveRemoval(objectName, pendingRemoval{Frame: frame, Attempt: attempt})
+
+		delay *= 2
+		if delay > maxRemoveRetryInterval {
+			delay = maxRemoveRetryInterval
+		}
+	}
+	driver.Logger.Error(fmt.Sprintf("Bo qua xoa %s khoi mang sau %d lan thu lai", objectName, cfg.retries))
+	forgetRemoval(objectName)
+}
+
+// resumeRemovals : tiep tuc cac yeu cau xoa dang thu lai truoc khi service dung
+func (d *Driver) resumeRemovals() {
+	cfg := currentRemoval()
+	err := stateStore().ForEach(bucketRemovals, func(objectName string, raw json.RawMessage) error {
+		var r pendingRemoval
+		if err := json.Unmarshal(raw, &r); err != nil {
+			driver.Logger.Warn(fmt.Sprintf("Bo qua yeu cau xoa %s: %v", objectName, err))
+			forgetRemoval(objectName)
+			return nil
+		}
+		driver.Logger.Info(fmt.Sprintf("Tiep tuc xoa %s (Address=%d) khoi mang, da thu %d lan",
+			objectName, r.Frame.Address, r.Attempt))
+		go d.retryRemoveObject(objectName, r, cfg)
+		return nil
+	})
+	logStateError("resume removals", err)
+}
+
+// sendDeleteObject : gui DeleteObjectFrame va cho status phan hoi
+func (d *Driver) sendDeleteObject(ctx context.Context, frame DeleteObjectFrame) error {
+	if !LinkIsUp() {
+		return ErrLinkDown
+	}
+	_, err := sendAndAwait(ctx, request{
+		cmd: DeleteObjectCmdConst,
+		key: packet.Repo().GetRepoNameByCMD(DeleteObjectCmdConst),
+		frame: func(seq uint8) interface{} {
+			frame.Seq = seq
+			return frame
+		},
+		dest:    destinationOfAddress(frame.ObjectAddress),
+		timeout: currentTimeouts().response,
+	})
+	return err
+}
@@ -0,0 +1,130 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/device-zigbee/driver/capture"
+	"github.com/device-zigbee/driver/frame"
+	"github.com/edgexfoundry/go-mod-core-contracts/models"
+)
+
+// ReplayEvent : 1 frame cua file capture sau khi qua Decoder va object cache
+type ReplayEvent struct {
+	Time     time.Time
+	Dir      capture.Direction
+	Cmd      string
+	Seq      uint8
+	Object   string // ten doi tuong theo dia chi, rong neu cache khong biet dia chi
+	Address  *ObjectAddress
+	Resource string      // ten resource theo AttributeInfo
+	Status   string      // status ZCL cua phan hoi
+	Value    interface{} // gia tri doc/ghi/PushEvent
+	Payload  string
+	Err      error // loi frame (CRC, Lenght) hoac JSON
+}
+
+// cmdNames : ten cac Cmd cua frame UART
+var cmdNames = map[byte]string{
+	CommandCmdConst:        "Command",
+	PushEventCmdConst:      "PushEvent",
+	AddObjectCmdConst:      "AddObject",
+	DeleteObjectCmdConst:   "DeleteObject",
+	ScanCmdConst:           "Scan",
+	DeviceAnnounceCmdConst: "DeviceAnnounce",
+}
+
+// Replay : phat lai file capture qua Decoder va object cache dung tu devices
+// (vi du danh sach lay tu core-metadata). Khong gui gi toi transport hay EdgeX,
+// chi dung de tai hien loi ngoai hien truong. fn duoc goi voi moi frame
+func Replay(r *capture.Reader, devices []models.Device, fn func(ReplayEvent)) error {
+	initOnce.Do(func() {
+		oc = newObjectCache(nil)
+	})
+	for _, d := range devices {
+		oc.UpdateObject(d)
+	}
+
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		decoder := frame.NewDecoder(bytes.NewReader(rec.Data), frame.MaxLength)
+		for {
+			f, err := decoder.Decode()
+			if err == io.EOF {
+				break
+			}
+			ev := ReplayEvent{Time: rec.Time, Dir: rec.Dir}
+			if err != nil {
+				ev.Err = err
+				fn(ev)
+				if !frame.IsFrameError(err) {
+					break
+				}
+				continue
+			}
+			describeFrame(&ev, f)
+			fn(ev)
+		}
+	}
+}
+
+// describeFrame : giai ma payload JSON va tim ten doi tuong/resource trong cache
+func describeFrame(ev *ReplayEvent, f UARTFrame) {
+	ev.Cmd = cmdNames[f.Cmd]
+	if ev.Cmd == "" {
+		ev.Cmd = "Unknown"
+	}
+	ev.Payload = string(f.Payload)
+
+	var addr ObjectAddress
+	var att AttributeInfo
+	var hasAtt bool
+	if ev.Dir == capture.TX {
+		switch f.Cmd {
+		case CommandCmdConst:
+			var c CommandFrame
+			ev.Err = json.Unmarshal(f.Payload, &c)
+			addr, att, hasAtt = c.ObjectAddress, c.AttributeInfo, true
+			ev.Seq = c.Seq
+			ev.Value = c.Value
+		case DeleteObjectCmdConst:
+			var c DeleteObjectFrame
+			ev.Err = json.Unmarshal(f.Payload, &c)
+			addr = c.ObjectAddress
+			ev.Seq = c.Seq
+		default:
+			return
+		}
+	} else {
+		var c ResponseCommonFrame
+		ev.Err = json.Unmarshal(f.Payload, &c)
+		addr, att = c.ObjectAddress, c.AttributeInfo
+		hasAtt = f.Cmd == CommandCmdConst || f.Cmd == PushEventCmdConst
+		ev.Seq = c.Seq
+		ev.Value = c.Value
+		if f.Cmd != PushEventCmdConst && f.Cmd != DeviceAnnounceCmdConst {
+			ev.Status = ZCLStatusName(c.StatusResponse)
+		}
+	}
+	if ev.Err != nil {
+		return
+	}
+
+	ev.Address = &addr
+	if id, ok := Cache().ConvertAddrToIDObject(addr); ok {
+		ev.Object, _ = Cache().ConvertIDToNameObject(id)
+	}
+	if hasAtt && ev.Object != "" {
+		if res, ok := Cache().ConvertAttToRes(ev.Object, att); ok {
+			ev.Resource = res.Name
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// request : 1 yeu cau gui toi coordinator va cho phan hoi co status
+type request struct {
+	cmd     int8
+	key     string                      // ten Repo theo ID/CMD, dung cho firmware khong tra lai seq
+	frame   func(seq uint8) interface{} // frame gui di voi seq da cap
+	dest    string                      // cua so truyen, xem destinationOfAddress
+	timeout time.Duration               // thoi gian cho phan hoi
+}
+
+// sendAndAwait : cap seq, gui frame trong cua so truyen cua dest roi cho phan hoi.
+// Status khac SUCCESS tra ve ZCLStatusError cung voi phan hoi
+func sendAndAwait(ctx context.Context, r request) (ResponseCommonFrame, error) {
+	seq, waiter, err := pendingRequests().add(r.key)
+	if err != nil {
+		return ResponseCommonFrame{}, err
+	}
+	defer pendingRequests().done(seq)
+
+	contentRepo := ContentRepo{
+		Cmd:     r.cmd,
+		Content: r.frame(seq),
+	}
+	sendCtx, cancelSend := context.WithTimeout(ctx, currentTimeouts().send)
+	defer cancelSend()
+	release, err := SendUartPacket(sendCtx, contentRepo, r.dest)
+	if err != nil {
+		return ResponseCommonFrame{}, err
+	}
+	defer release()
+	driver.Logger.Info(fmt.Sprintf("Send request: %+v", contentRepo))
+
+	waitCtx, cancelWait := context.WithTimeout(ctx, r.timeout)
+	defer cancelWait()
+	responseRaw, err := waiter.Wait(waitCtx)
+	if err != nil {
+		return ResponseCommonFrame{}, contextError(err)
+	}
+	response, ok := responseRaw.(ResponseCommonFrame)
+	if !ok {
+		return ResponseCommonFrame{}, ErrBadResponse
+	}
+	driver.Logger.Info(fmt.Sprintf("Response: %+v", response))
+	if response.StatusResponse != 0 {
+		return response, ZCLStatusError{response.StatusResponse}
+	}
+	return response, nil
+}
@@ -0,0 +1,122 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/device-zigbee/driver/packet"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/clients/logger"
+)
+
+// legacyContentRepo : dang packet.ContentRepoStruct truoc khi waiter nhan frame da phan tich
+type legacyContentRepo struct {
+	Packet interface{} `json:"content"`
+	Cmd    int8        `json:"cmd"`
+}
+
+// legacyReceive : duong nhan cu, frame duoc phan tich roi gui ContentRepo toi Repo theo ten
+func legacyReceive(rxFrame UARTFrame, nameRepo string) bool {
+	var content ResponseCommonFrame
+	err := json.Unmarshal(rxFrame.Payload, &content)
+	if err != nil {
+		return false
+	}
+	return packet.Repo().SendToRepo(nameRepo, ContentRepo{Cmd: int8(rxFrame.Cmd), Content: content})
+}
+
+// legacyDecodeResponse : cach cu, ContentRepo duoc marshal/unmarshal 2 lan de lay ResponseCommonFrame
+func legacyDecodeResponse(responseRaw interface{}) (ResponseCommonFrame, bool) {
+	respByte, _ := json.Marshal(responseRaw)
+	var responseRepo legacyContentRepo
+	err := json.Unmarshal(respByte, &responseRepo)
+	if err != nil {
+		return ResponseCommonFrame{}, false
+	}
+	respByte, _ = json.Marshal(responseRepo.Packet)
+	var response ResponseCommonFrame
+	err = json.Unmarshal(respByte, &response)
+	if err != nil {
+		return ResponseCommonFrame{}, false
+	}
+	return response, true
+}
+
+// benchResponse : phan hoi doc CurrentLevel cua 1 den
+func benchResponse(seq uint8) ResponseCommonFrame {
+	return ResponseCommonFrame{
+		ObjectInfo: ObjectInfo{
+			ObjectAddress: ObjectAddress{Address: 0x4f21, Type: 1, Endpoint: 1},
+		},
+		Seq: seq,
+		AttributeValue: AttributeValue{
+			AttributeInfo: AttributeInfo{ProfileID: 0x0104, ClusterID: 0x0008, AttributeID: 0x0000, ValueType: 0x20},
+			Value:         uint8(254),
+		},
+	}
+}
+
+func benchFrame(b *testing.B, seq uint8) UARTFrame {
+	payload, err := json.Marshal(benchResponse(seq))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return UARTFrame{Cmd: CommandCmdConst, Payload: payload}
+}
+
+// BenchmarkReceiveResponse : tu UARTFrame nhan duoc toi ResponseCommonFrame trong tay yeu cau dang cho.
+// marshal: Repo theo ten + marshal/unmarshal 2 lan (khong tinh tim dia chi trong cache);
+// typed: bang pending theo seq, waiter nhan frame da phan tich
+func BenchmarkReceiveResponse(b *testing.B) {
+	d := NewProtocolDriver().(*Driver)
+	if d.Logger == nil {
+		d.Logger = logger.NewMockClient()
+	}
+	ctx := context.Background()
+
+	b.Run("marshal", func(b *testing.B) {
+		const nameRepo = "bench"
+		rx := benchFrame(b, 0)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			w := packet.Repo().Register(nameRepo)
+			if !legacyReceive(rx, nameRepo) {
+				b.Fatal("receive failed")
+			}
+			raw, err := w.Wait(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, ok := legacyDecodeResponse(raw); !ok {
+				b.Fatal("decode failed")
+			}
+		}
+	})
+	b.Run("typed", func(b *testing.B) {
+		var frames [256]UARTFrame
+		for seq := 1; seq < len(frames); seq++ {
+			frames[seq] = benchFrame(b, uint8(seq))
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			seq, w, err := pendingRequests().add("bench")
+			if err != nil {
+				b.Fatal(err)
+			}
+			pendingRequests().markSent(seq, time.Now())
+			sendRXUartFrameToRepo(frames[seq])
+			raw, err := w.Wait(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, ok := raw.(ResponseCommonFrame); !ok {
+				b.Fatal("decode failed")
+			}
+			pendingRequests().done(seq)
+		}
+	})
+}
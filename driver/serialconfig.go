@@ -0,0 +1,121 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// ten cac khoa cau hinh cong serial trong muc [Driver]
+const (
+	nameBaudConfig        = "Baud"
+	nameDataBitsConfig    = "DataBits"
+	nameParityConfig      = "Parity"
+	nameStopBitsConfig    = "StopBits"
+	nameReadTimeoutConfig = "ReadTimeout" // ms, 0 = doc cho den khi co du lieu
+	nameFlowControlConfig = "FlowControl"
+)
+
+const (
+	defaultBaud     = 9600
+	defaultDataBits = 8
+)
+
+// cac kieu flow control ho tro
+const (
+	flowControlNone    = "none"
+	flowControlRTSCTS  = "rtscts"
+	flowControlXONXOFF = "xonxoff"
+)
+
+var validBauds = map[int]bool{
+	1200: true, 2400: true, 4800: true, 9600: true, 19200: true, 38400: true,
+	57600: true, 115200: true, 230400: true, 460800: true, 921600: true,
+}
+
+// serialLineConfig : cau hinh duong truyen serial doc tu sdk.DriverConfigs()
+type serialLineConfig struct {
+	serial.Config
+	FlowControl string
+}
+
+// parseSerialLineConfig : doc va kiem tra cau hinh serial, tra ve loi ro rang khi gia tri khong hop le
+func parseSerialLineConfig(config map[string]string) (result serialLineConfig, err error) {
+	port, ok := config[nameSerialPortConfig]
+	if !ok || port == "" {
+		return result, fmt.Errorf("Khong chi dinh SerialPort")
+	}
+	result.Name = port
+	result.Baud = defaultBaud
+	result.Size = defaultDataBits
+	result.Parity = serial.ParityNone
+	result.StopBits = serial.Stop1
+	result.FlowControl = flowControlNone
+
+	if v, ok := configValue(config, nameBaudConfig); ok {
+		baud, err := strconv.Atoi(v)
+		if err != nil || !validBauds[baud] {
+			return result, fmt.Errorf("Cau hinh %s khong hop le: %s", nameBaudConfig, v)
+		}
+		result.Baud = baud
+	}
+
+	if v, ok := configValue(config, nameDataBitsConfig); ok {
+		size, err := strconv.ParseUint(v, 10, 8)
+		if err != nil || size < 5 || size > 8 {
+			return result, fmt.Errorf("Cau hinh %s khong hop le: %s (5-8)", nameDataBitsConfig, v)
+		}
+		result.Size = byte(size)
+	}
+
+	if v, ok := configValue(config, nameParityConfig); ok {
+		switch strings.ToLower(v) {
+		case "n", "none":
+			result.Parity = serial.ParityNone
+		case "o", "odd":
+			result.Parity = serial.ParityOdd
+		case "e", "even":
+			result.Parity = serial.ParityEven
+		default:
+			return result, fmt.Errorf("Cau hinh %s khong hop le: %s (none, odd, even)", nameParityConfig, v)
+		}
+	}
+
+	if v, ok := configValue(config, nameStopBitsConfig); ok {
+		switch v {
+		case "1":
+			result.StopBits = serial.Stop1
+		case "2":
+			result.StopBits = serial.Stop2
+		default:
+			return result, fmt.Errorf("Cau hinh %s khong hop le: %s (1, 2)", nameStopBitsConfig, v)
+		}
+	}
+
+	if v, ok := configValue(config, nameReadTimeoutConfig); ok {
+		ms, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return result, fmt.Errorf("Cau hinh %s khong hop le: %s", nameReadTimeoutConfig, v)
+		}
+		result.ReadTimeout = time.Duration(ms) * time.Millisecond
+	}
+
+	if v, ok := configValue(config, nameFlowControlConfig); ok {
+		flow := strings.ToLower(v)
+		if flow != flowControlNone && flow != flowControlRTSCTS && flow != flowControlXONXOFF {
+			return result, fmt.Errorf("Cau hinh %s khong hop le: %s (none, rtscts, xonxoff)", nameFlowControlConfig, v)
+		}
+		result.FlowControl = flow
+	}
+	return result, nil
+}
+
+// configValue : lay gia tri cau hinh, bo qua khoa rong
+func configValue(config map[string]string, key string) (string, bool) {
+	v, ok := config[key]
+	v = strings.TrimSpace(v)
+	return v, ok && v != ""
+}
@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	sdk "github.com/edgexfoundry/device-sdk-go"
+	"github.com/edgexfoundry/go-mod-core-contracts/models"
+	"github.com/pelletier/go-toml"
+)
+
+// file cau hinh SDK doc khi khong co -confdir/-profile (startup.Bootstrap)
+const (
+	sdkConfigDir  = "./res"
+	sdkConfigFile = "configuration.toml"
+)
+
+// deviceService : cac ham cua device service (SDK) ma driver dung.
+// Test thay bang service trong bo nho qua setRunningService
+type deviceService interface {
+	Name() string
+	DriverConfigs() map[string]string
+	MetadataURL() (string, error)
+	AddRoute(route string, handler func(http.ResponseWriter, *http.Request), methods ...string) error
+	Devices() []models.Device
+	GetDeviceByName(name string) (models.Device, error)
+	AddDevice(device models.Device) (string, error)
+	UpdateDevice(device models.Device) error
+	DeviceProfiles() []models.DeviceProfile
+	DeviceResource(deviceName string, deviceResource string, method string) (models.DeviceResource, bool)
+}
+
+// sdkService : service cua SDK, DriverConfigs la ham cua package sdk
+type sdkService struct {
+	*sdk.Service
+}
+
+func (sdkService) DriverConfigs() map[string]string {
+	return sdk.DriverConfigs()
+}
+
+// MetadataURL : dia chi core-metadata theo [Clients.Metadata] cua file cau hinh ma SDK da doc.
+// SDK khong cong khai cau hinh client nen doc lai file theo -confdir/-profile cua startup.Bootstrap
+func (sdkService) MetadataURL() (string, error) {
+	dir := flagValue("confdir")
+	if dir == "" {
+		dir = sdkConfigDir
+	}
+	if profile := flagValue("profile"); profile != "" {
+		dir = filepath.Join(dir, profile)
+	}
+	return clientURL(filepath.Join(dir, sdkConfigFile), "Metadata")
+}
+
+// flagValue : gia tri co dong lenh da dang ky, rong neu khong co
+func flagValue(name string) string {
+	f := flag.Lookup(name)
+	if f == nil {
+		return ""
+	}
+	return f.Value.String()
+}
+
+// clientURL : dia chi cua [Clients.<name>] trong file cau hinh, dang Protocol://Host:Port nhu SDK
+func clientURL(file string, name string) (string, error) {
+	tree, err := toml.LoadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("Khong doc duoc file cau hinh %s: %v", file, err)
+	}
+	prefix := "Clients." + name + "."
+	protocol, _ := tree.Get(prefix + "Protocol").(string)
+	host, _ := tree.Get(prefix + "Host").(string)
+	port, _ := tree.Get(prefix + "Port").(int64)
+	if protocol == "" {
+		protocol = "http"
+	}
+	if host == "" || port <= 0 {
+		return "", fmt.Errorf("Cau hinh [Clients.%s] khong hop le: %s", name, file)
+	}
+	return fmt.Sprintf("%s://%s:%d", protocol, host, port), nil
+}
+
+var (
+	serviceMutex   sync.Mutex
+	currentService deviceService // nil = sdk.RunningService()
+)
+
+// runningService : device service dang chay
+func runningService() deviceService {
+	serviceMutex.Lock()
+	defer serviceMutex.Unlock()
+
+	if currentService == nil {
+		return sdkService{sdk.RunningService()}
+	}
+	return currentService
+}
+
+// setRunningService : thay device service, nil = dung lai service cua SDK
+func setRunningService(s deviceService) {
+	serviceMutex.Lock()
+	currentService = s
+	serviceMutex.Unlock()
+}
@@ -0,0 +1,22 @@
+package driver
+
+import "testing"
+
+func TestClientURL(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"../cmd/res/configuration.toml", "http://localhost:48081"},
+		{"../cmd/res/docker/configuration.toml", "http://edgex-core-metadata:48081"},
+	}
+	for _, tt := range tests {
+		got, err := clientURL(tt.file, "Metadata")
+		if err != nil || got != tt.want {
+			t.Fatalf("clientURL(%s) = %q, %v, want %q", tt.file, got, err, tt.want)
+		}
+	}
+	if _, err := clientURL("../cmd/res/configuration.toml", "Missing"); err == nil {
+		t.Fatal("clientURL of a missing client: want error")
+	}
+}
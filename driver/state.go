@@ -0,0 +1,308 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/device-zigbee/driver/store"
+)
+
+// ten cac khoa cau hinh luu trang thai trong muc [Driver]
+const (
+	nameStateDirConfig          = "StateDir"          // thu muc luu trang thai, rong = chi giu trong bo nho
+	nameStateCompactSizeConfig  = "StateCompactSize"  // KB, journal vuot qua thi compact
+	nameStateSyncIntervalConfig = "StateSyncInterval" // ms, 0 = fsync sau moi lan ghi
+)
+
+const (
+	defaultStateCompactSize  = 1024 // KB
+	defaultStateSyncInterval = 1000 * time.Millisecond
+)
+
+// cac bucket trong kho trang thai
+const (
+	bucketRemovals = "removals" // xoa doi tuong dang thu lai, theo ten doi tuong
+	bucketValues   = "values"   // gia tri cuoi cung cua resource, theo "<doi tuong>/<resource>"
+	bucketTables   = "tables"   // muc Subscribe/Schedule da gui toi coordinator, xem tableKey
+)
+
+// stateTablesRoute : REST endpoint tra ve cac muc Subscribe/Schedule da gui toi coordinator
+const stateTablesRoute = "/api/v1/zigbee/tables"
+
+var (
+	stateMutex sync.Mutex
+	state      store.Store
+)
+
+// initState : mo kho trang thai theo muc [Driver] cua configuration.toml
+func initState(config map[string]string) error {
+	dir, ok := configValue(config, nameStateDirConfig)
+	if !ok {
+		stateMutex.Lock()
+		state = store.NewMemory()
+		stateMutex.Unlock()
+		return nil
+	}
+	opts := store.Options{
+		CompactSize:  defaultStateCompactSize * 1024,
+		SyncInterval: defaultStateSyncInterval,
+		CompactError: func(err error) { logStateError("compact", err) },
+		ReplayError:  func(err error) { logStateError("replay", err) },
+	}
+	if v, ok := configValue(config, nameStateCompactSizeConfig); ok {
+		kb, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameStateCompactSizeConfig, v)
+		}
+		opts.CompactSize = int64(kb) * 1024
+	}
+	if v, ok := configValue(config, nameStateSyncIntervalConfig); ok {
+		ms, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameStateSyncIntervalConfig, v)
+		}
+		opts.SyncInterval = time.Duration(ms) * time.Millisecond
+	}
+
+	j, err := store.Open(dir, opts)
+	if err != nil {
+		return fmt.Errorf("Khong mo duoc kho trang thai %s: %v", dir, err)
+	}
+	// gom journal cua lan chay truoc
+	err = j.Compact()
+	if err != nil {
+		j.Close()
+		return fmt.Errorf("Khong mo duoc kho trang thai %s: %v", dir, err)
+	}
+	stateMutex.Lock()
+	state = j
+	stateMutex.Unlock()
+	driver.Logger.Info(fmt.Sprintf("Luu trang thai vao %s", dir))
+	return nil
+}
+
+// stateStore : kho trang thai, chua initState thi dung kho trong bo nho
+func stateStore() store.Store {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	if state == nil {
+		state = store.NewMemory()
+	}
+	return state
+}
+
+// closeState : ghi not va dong kho trang thai
+func closeState() {
+	stateMutex.Lock()
+	s := state
+	state = nil
+	stateMutex.Unlock()
+	if s != nil {
+		s.Close()
+	}
+}
+
+// logStateError : loi kho trang thai khong lam hong lenh dang xu ly, chi ghi log
+func logStateError(operation string, err error) {
+	if err != nil && err != store.ErrClosed {
+		driver.Logger.Error(fmt.Sprintf("Kho trang thai: %s: %v", operation, err))
+	}
+}
+
+// pendingRemoval : yeu cau xoa doi tuong khoi mang dang thu lai
+type pendingRemoval struct {
+	Frame   DeleteObjectFrame `json:"frame"`
+	Attempt int               `json:"attempt"` // so lan da thu lai
+}
+
+func saveRemoval(objectName string, r pendingRemoval) {
+	logStateError("save removal "+objectName, stateStore().Put(bucketRemovals, objectName, r))
+}
+
+func forgetRemoval(objectName string) {
+	logStateError("forget removal "+objectName, stateStore().Delete(bucketRemovals, objectName))
+}
+
+// lastValue : gia tri tho cuoi cung cua resource nhan tu thiet bi
+type lastValue struct {
+	Value  interface{} `json:"val"`
+	Origin int64       `json:"origin"` // ns
+}
+
+func valueKey(objectName string, resource string) string {
+	return objectName + "/" + resource
+}
+
+var (
+	valuesMutex sync.Mutex
+	// valueOrigins : Origin moi hon cua gia tri cuoi khong doi, chi giu trong bo nho
+	// de khong ghi lai kho (flash) moi lan doc/PushEvent cung gia tri
+	valueOrigins = make(map[string]int64)
+)
+
+// saveValue : ghi gia tri tho vua doc/nhan duoc cua resource.
+// Gia tri giong gia tri da luu thi chi cap nhat Origin trong bo nho
+func saveValue(objectName string, resource string, raw interface{}, origin int64) {
+	key := valueKey(objectName, resource)
+	valuesMutex.Lock()
+	defer valuesMutex.Unlock()
+
+	if sameStoredValue(key, raw) {
+		if origin > valueOrigins[key] {
+			valueOrigins[key] = origin
+		}
+		return
+	}
+	delete(valueOrigins, key)
+	err := stateStore().Put(bucketValues, key, lastValue{Value: raw, Origin: origin})
+	logStateError("save value "+key, err)
+}
+
+// sameStoredValue : kho da co gia tri raw cho key (so sanh dang JSON)
+func sameStoredValue(key string, raw interface{}) bool {
+	var stored struct {
+		Value json.RawMessage `json:"val"`
+	}
+	ok, err := stateStore().Get(bucketValues, key, &stored)
+	if err != nil || !ok {
+		return false
+	}
+	b, err := json.Marshal(raw)
+	return err == nil && bytes.Equal(b, stored.Value)
+}
+
+// valueOrigin : Origin moi nhat cua gia tri cuoi, stored la Origin trong kho
+func valueOrigin(key string, stored int64) int64 {
+	valuesMutex.Lock()
+	defer valuesMutex.Unlock()
+	if origin := valueOrigins[key]; origin > stored {
+		return origin
+	}
+	return stored
+}
+
+// forgetValueOrigin : bo Origin trong bo nho cua gia tri cuoi da xoa khoi kho
+func forgetValueOrigin(key string) {
+	valuesMutex.Lock()
+	delete(valueOrigins, key)
+	valuesMutex.Unlock()
+}
+
+// coordinatorEntry : muc Subscribe/Schedule da duoc coordinator chap nhan
+type coordinatorEntry struct {
+	Command string    `json:"command"`
+	Object  string    `json:"object"`
+	Body    string    `json:"body"`
+	Updated time.Time `json:"updated"`
+}
+
+// tableKey : "<lenh>/<doi tuong>/<owner>/<element hoac ten schedule>"
+func tableKey(command string, objectName string, ownerID string, name string) string {
+	return strings.Join([]string{command, objectName, ownerID, name}, "/")
+}
+
+// saveCoordinatorEntry : cap nhat bang sau khi coordinator chap nhan lenh cua manager device
+func saveCoordinatorEntry(command string, method string, objectName string, key string, body string) {
+	var err error
+	switch {
+	case command == managerRemoveItself:
+		forgetCoordinatorEntries(objectName)
+		return
+	case key == "":
+		return
+	case method == managerDeleteMethod:
+		err = stateStore().Delete(bucketTables, key)
+	default:
+		err = stateStore().Put(bucketTables, key, coordinatorEntry{
+			Command: command,
+			Object:  objectName,
+			Body:    body,
+			Updated: time.Now(),
+		})
+	}
+	logStateError("save table "+key, err)
+}
+
+// forgetCoordinatorEntries : xoa moi muc cua doi tuong
+func forgetCoordinatorEntries(objectName string) {
+	var keys []string
+	err := stateStore().ForEach(bucketTables, func(key string, raw json.RawMessage) error {
+		var e coordinatorEntry
+		if json.Unmarshal(raw, &e) == nil && e.Object == objectName {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	logStateError("list tables", err)
+	for _, key := range keys {
+		logStateError("forget table "+key, stateStore().Delete(bucketTables, key))
+	}
+}
+
+// membersOf : ten cac doi tuong da Subscribe vao group/scenario ownerID theo bang cua coordinator
+func membersOf(ownerID string) []string {
+	var members []string
+	err := stateStore().ForEach(bucketTables, func(key string, raw json.RawMessage) error {
+		var e coordinatorEntry
+		if json.Unmarshal(raw, &e) != nil || e.Command != managerSubcribe {
+			return nil
+		}
+		var content contentElementType
+		if json.Unmarshal([]byte(e.Body), &content) == nil && content.OwnerID == ownerID {
+			members = append(members, e.Object)
+		}
+		return nil
+	})
+	logStateError("list tables", err)
+	return members
+}
+
+// forgetObjectState : doi tuong bi xoa khoi EdgeX, bo bang va gia tri cua no.
+// Yeu cau xoa dang thu lai duoc giu cho den khi xong
+func forgetObjectState(objectName string) {
+	forgetCoordinatorEntries(objectName)
+	forgetObjectValues(objectName)
+}
+
+// forgetObjectValues : bo moi gia tri cuoi cua doi tuong
+func forgetObjectValues(objectName string) {
+	var keys []string
+	prefix := valueKey(objectName, "")
+	err := stateStore().ForEach(bucketValues, func(key string, raw json.RawMessage) error {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	logStateError("list values", err)
+	for _, key := range keys {
+		logStateError("forget value "+key, stateStore().Delete(bucketValues, key))
+		forgetValueOrigin(key)
+	}
+}
+
+// stateTablesHandler : GET stateTablesRoute
+func stateTablesHandler(w http.ResponseWriter, r *http.Request) {
+	entries := make(map[string]coordinatorEntry)
+	err := stateStore().ForEach(bucketTables, func(key string, raw json.RawMessage) error {
+		var e coordinatorEntry
+		err := json.Unmarshal(raw, &e)
+		if err != nil {
+			return err
+		}
+		entries[key] = e
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
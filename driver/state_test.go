@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/device-zigbee/driver/store"
+)
+
+// TestSaveValueUnchanged : gia tri khong doi khong ghi lai journal, chi Origin moi hon
+func TestSaveValueUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	j, err := store.Open(dir, store.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateMutex.Lock()
+	state = j
+	stateMutex.Unlock()
+	defer closeState()
+
+	saveValue("Light-01", "Light", json.Number("1"), 100)
+	size := j.Size()
+	saveValue("Light-01", "Light", json.Number("1"), 200)
+	if j.Size() != size {
+		t.Fatalf("journal grew from %d to %d for an unchanged value", size, j.Size())
+	}
+	if v, ok := lookupValue("Light-01", "Light"); !ok || v.Origin != 200 {
+		t.Fatalf("lookupValue = %+v, %v, want origin 200", v, ok)
+	}
+
+	saveValue("Light-01", "Light", json.Number("0"), 300)
+	if j.Size() == size {
+		t.Fatal("changed value was not written")
+	}
+	if v, ok := lookupValue("Light-01", "Light"); !ok || v.Origin != 300 || v.Value != json.Number("0") {
+		t.Fatalf("lookupValue = %+v, %v, want 0 at 300", v, ok)
+	}
+
+	forgetValue("Light-01", "Light")
+	if _, ok := lookupValue("Light-01", "Light"); ok {
+		t.Fatal("value still present after forgetValue")
+	}
+	saveValue("Light-01", "Light", json.Number("0"), 50)
+	if v, _ := lookupValue("Light-01", "Light"); v.Origin != 50 {
+		t.Fatalf("origin = %d after forget and save, want 50", v.Origin)
+	}
+}
@@ -0,0 +1,146 @@
+package driver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/device-zigbee/driver/frame"
+)
+
+const (
+	// linkStatsResource : resource chi doc cua manager device, tra ve LinkStats dang JSON
+	linkStatsResource = "LinkStats"
+	// linkStatsRoute : REST endpoint tra ve LinkStats dang JSON
+	linkStatsRoute = "/api/v1/zigbee/stats"
+)
+
+// LinkStats : thong ke ket noi toi coordinator tu khi service khoi dong.
+// CRC/Length/ShortFrames/Resyncs tang -> loi duong truyen (cap, nhieu);
+// ResponseTimeouts tang ma duong truyen sach -> thiet bi khong tra loi.
+type LinkStats struct {
+	FramesSent     uint64 `json:"framesSent"`
+	FramesReceived uint64 `json:"framesReceived"`
+	BytesSent      uint64 `json:"bytesSent"`
+	BytesReceived  uint64 `json:"bytesReceived"`
+
+	CRCErrors      uint64 `json:"crcErrors"`
+	LengthErrors   uint64 `json:"lengthErrors"`
+	ShortFrames    uint64 `json:"shortFrames"`
+	Resyncs        uint64 `json:"resyncs"`
+	DiscardedBytes uint64 `json:"discardedBytes"`
+
+	UnknownCmd      uint64 `json:"unknownCmd"`
+	JSONErrors      uint64 `json:"jsonErrors"`
+	UnknownAddress  uint64 `json:"unknownAddress"`
+	UnmatchedFrames uint64 `json:"unmatchedFrames"` // phan hoi khong con yeu cau nao cho
+	AddressChanges  uint64 `json:"addressChanges"`  // thiet bi tham gia lai mang voi dia chi ngan moi
+
+	Responses        uint64  `json:"responses"`
+	ResponseTimeouts uint64  `json:"responseTimeouts"`
+	AvgRoundTripMs   float64 `json:"avgRoundTripMs"`
+	MaxRoundTripMs   float64 `json:"maxRoundTripMs"`
+
+	LinkFailures uint64 `json:"linkFailures"`
+	LinkState    string `json:"linkState"`
+	Since        string `json:"since"`
+}
+
+type linkCounters struct {
+	mutex    sync.Mutex
+	s        LinkStats
+	rttTotal time.Duration
+	rttMax   time.Duration
+	since    time.Time
+}
+
+var counters = linkCounters{since: time.Now()}
+
+// update : cap nhat thong ke trong mutex
+func (c *linkCounters) update(f func(s *LinkStats)) {
+	c.mutex.Lock()
+	f(&c.s)
+	c.mutex.Unlock()
+}
+
+func (c *linkCounters) addRoundTrip(rtt time.Duration) {
+	c.mutex.Lock()
+	c.s.Responses++
+	c.rttTotal += rtt
+	if rtt > c.rttMax {
+		c.rttMax = rtt
+	}
+	c.mutex.Unlock()
+}
+
+// addDecoderStats : cong phan tang them cua thong ke Decoder ke tu lan truoc
+func (c *linkCounters) addDecoderStats(now frame.DecoderStats, last *frame.DecoderStats) {
+	c.mutex.Lock()
+	c.s.Resyncs += now.Resyncs - last.Resyncs
+	c.s.DiscardedBytes += now.DiscardedBytes - last.DiscardedBytes
+	c.mutex.Unlock()
+	*last = now
+}
+
+func (c *linkCounters) addFrameError(err error) {
+	c.mutex.Lock()
+	switch err.(type) {
+	case *frame.CRCError:
+		c.s.CRCErrors++
+	case *frame.LengthError:
+		c.s.LengthErrors++
+	default:
+		c.s.ShortFrames++
+	}
+	c.mutex.Unlock()
+}
+
+// linkStats : ban sao thong ke hien tai
+func linkStats() LinkStats {
+	c := &counters
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.s
+	if s.Responses > 0 {
+		s.AvgRoundTripMs = float64(c.rttTotal) / float64(s.Responses) / float64(time.Millisecond)
+	}
+	s.MaxRoundTripMs = float64(c.rttMax) / float64(time.Millisecond)
+	s.LinkState = CurrentLinkState().String()
+	s.Since = c.since.UTC().Format(time.RFC3339)
+	return s
+}
+
+func linkStatsJSON() (string, error) {
+	b, err := json.Marshal(linkStats())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// linkStatsHandler : GET linkStatsRoute
+func linkStatsHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := linkStatsJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, body)
+}
+
+// countingReader : dem so byte doc duoc tu transport
+type countingReader struct {
+	r io.Reader
+}
+
+func (c countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	if n > 0 {
+		counters.update(func(s *LinkStats) { s.BytesReceived += uint64(n) })
+	}
+	return n, err
+}
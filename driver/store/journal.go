@@ -0,0 +1,340 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	snapshotFile = "state.json"
+	journalFile  = "state.journal"
+
+	opPut    = "put"
+	opDelete = "del"
+)
+
+// Options : tuy chon cua Journal
+type Options struct {
+	// CompactSize : journal vuot qua so byte nay thi tu compact, 0 = chi compact khi goi Compact
+	CompactSize int64
+	// SyncInterval : 0 = fsync sau moi lan ghi; > 0 = fsync dinh ky,
+	// mat nguon co the mat cac thay doi trong khoang nay nhung file khong bi hong
+	SyncInterval time.Duration
+	// CompactError : nhan loi khi tu compact sau 1 lan ghi (thay doi da duoc ghi), nil = bo qua.
+	// Journal tiep tuc lon len va duoc compact lai o lan ghi sau
+	CompactError func(err error)
+	// ReplayError : nhan loi cua tung dong hong giua journal khi mo, nil = bo qua.
+	// Dong hong duoc bo qua, cac dong sau van duoc phat lai
+	ReplayError func(err error)
+}
+
+// file : file journal dang mo (*os.File), test thay de gia lap ghi/fsync loi
+type file interface {
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// record : 1 dong cua journal
+type record struct {
+	Op     string          `json:"op"`
+	Bucket string          `json:"b"`
+	Key    string          `json:"k"`
+	Value  json.RawMessage `json:"v,omitempty"`
+}
+
+// Journal : kho luu tren dia dang snapshot + journal JSON
+type Journal struct {
+	data
+	dir   string
+	opts  Options
+	file  file
+	size  int64
+	dirty bool
+	done  chan struct{}
+}
+
+// Open : mo (hoac tao) kho trong thu muc dir, phuc hoi du lieu tu snapshot va journal
+func Open(dir string, opts Options) (*Journal, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{
+		data: newData(),
+		dir:  dir,
+		opts: opts,
+		done: make(chan struct{}),
+	}
+	err = j.loadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	err = j.replay()
+	if err != nil {
+		return nil, err
+	}
+	if opts.SyncInterval > 0 {
+		go j.syncLoop()
+	}
+	return j, nil
+}
+
+func (j *Journal) path(name string) string {
+	return filepath.Join(j.dir, name)
+}
+
+func (j *Journal) loadSnapshot() error {
+	b, err := ioutil.ReadFile(j.path(snapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(b, &j.buckets)
+	if err != nil {
+		return fmt.Errorf("store: invalid snapshot %s: %v", j.path(snapshotFile), err)
+	}
+	if j.buckets == nil {
+		j.buckets = make(map[string]map[string]json.RawMessage)
+	}
+	return nil
+}
+
+// replay : phat lai journal. Chi phan sau '\n' cuoi cung (dong ghi do khi mat nguon) bi cat;
+// dong hong da co '\n' nam giua journal duoc bo qua va bao qua ReplayError
+func (j *Journal) replay() error {
+	f, err := os.OpenFile(j.path(journalFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	var end int64
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// dong cuoi khong co '\n' la dong ghi do
+			break
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+		end += int64(len(line))
+		var rec record
+		err = json.Unmarshal(bytes.TrimSpace(line), &rec)
+		if err == nil && !j.apply(rec) {
+			err = fmt.Errorf("unknown op %q", rec.Op)
+		}
+		if err != nil && j.opts.ReplayError != nil {
+			j.opts.ReplayError(fmt.Errorf("store: skipped journal line %d: %v", n, err))
+		}
+	}
+	err = f.Truncate(end)
+	if err == nil {
+		_, err = f.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	j.file = f
+	j.size = end
+	return nil
+}
+
+func (j *Journal) apply(rec record) bool {
+	switch rec.Op {
+	case opPut:
+		j.set(rec.Bucket, rec.Key, rec.Value)
+	case opDelete:
+		j.unset(rec.Bucket, rec.Key)
+	default:
+		return false
+	}
+	return true
+}
+
+// append : ghi 1 thay doi vao journal roi ap dung vao bo nho, goi trong mutex.
+// Ghi hoac fsync loi thi cat bo dong vua ghi, bo nho va dia khong lech nhau
+func (j *Journal) append(rec record) error {
+	if j.closed {
+		return ErrClosed
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	n, err := j.file.Write(line)
+	if err == nil && j.opts.SyncInterval == 0 {
+		err = j.file.Sync()
+	}
+	if err != nil {
+		// bo phan ghi do, giu journal doc duoc
+		j.file.Truncate(j.size)
+		j.file.Seek(j.size, io.SeekStart)
+		return err
+	}
+	j.size += int64(n)
+	if j.opts.SyncInterval > 0 {
+		j.dirty = true
+	}
+	j.apply(rec)
+
+	if j.opts.CompactSize > 0 && j.size > j.opts.CompactSize {
+		// thay doi da duoc luu, loi compact khong phai loi cua lan ghi nay
+		if err := j.compactWithoutSync(); err != nil && j.opts.CompactError != nil {
+			j.opts.CompactError(err)
+		}
+	}
+	return nil
+}
+
+func (j *Journal) Put(bucket, key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.append(record{Op: opPut, Bucket: bucket, Key: key, Value: raw})
+}
+
+func (j *Journal) Get(bucket, key string, value interface{}) (bool, error) {
+	return j.get(bucket, key, value)
+}
+
+func (j *Journal) Delete(bucket, key string) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if _, ok := j.buckets[bucket][key]; !ok {
+		return nil
+	}
+	return j.append(record{Op: opDelete, Bucket: bucket, Key: key})
+}
+
+func (j *Journal) ForEach(bucket string, fn func(key string, value json.RawMessage) error) error {
+	return j.forEach(bucket, fn)
+}
+
+// Compact : ghi snapshot moi va xoa journal
+func (j *Journal) Compact() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.closed {
+		return ErrClosed
+	}
+	return j.compactWithoutSync()
+}
+
+func (j *Journal) compactWithoutSync() error {
+	b, err := json.Marshal(j.buckets)
+	if err != nil {
+		return err
+	}
+	tmp := j.path(snapshotFile + ".tmp")
+	err = writeFileSync(tmp, b)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	err = os.Rename(tmp, j.path(snapshotFile))
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(j.dir)
+	// snapshot da chua moi thay doi; mat nguon truoc khi cat journal thi
+	// phat lai journal cu len snapshot moi van cho cung ket qua
+	err = j.file.Truncate(0)
+	if err == nil {
+		_, err = j.file.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = j.file.Sync()
+	}
+	if err != nil {
+		return err
+	}
+	j.size = 0
+	j.dirty = false
+	return nil
+}
+
+// Size : kich thuoc journal hien tai (byte)
+func (j *Journal) Size() int64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.size
+}
+
+func (j *Journal) syncLoop() {
+	ticker := time.NewTicker(j.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.mutex.Lock()
+			if j.dirty && !j.closed {
+				if j.file.Sync() == nil {
+					j.dirty = false
+				}
+			}
+			j.mutex.Unlock()
+		case <-j.done:
+			return
+		}
+	}
+}
+
+func (j *Journal) Close() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.closed {
+		return nil
+	}
+	j.closed = true
+	close(j.done)
+	err := j.file.Sync()
+	if cerr := j.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeFileSync(name string, b []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncDir : fsync thu muc de doi ten file duoc ghi xuong dia.
+// Mot so he thong (Windows) khong ho tro fsync thu muc, loi duoc bo qua
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
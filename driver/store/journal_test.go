@@ -0,0 +1,265 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tempDir : thu muc tam cho 1 test (t.TempDir can Go 1.15)
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// contents : bucket "b" cua kho duoi dang key -> JSON
+func contents(t *testing.T, s Store) map[string]string {
+	result := make(map[string]string)
+	err := s.ForEach("b", func(key string, value json.RawMessage) error {
+		result[key] = string(value)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestReplay(t *testing.T) {
+	const (
+		putA = `{"op":"put","b":"b","k":"a","v":1}` + "\n"
+		putB = `{"op":"put","b":"b","k":"b","v":2}` + "\n"
+		delA = `{"op":"del","b":"b","k":"a"}` + "\n"
+	)
+	tests := []struct {
+		name     string
+		journal  string
+		want     map[string]string
+		skipped  int
+		wantSize int // kich thuoc journal sau khi mo
+	}{
+		{
+			name:     "clean",
+			journal:  putA + putB + delA,
+			want:     map[string]string{"b": "2"},
+			wantSize: len(putA + putB + delA),
+		},
+		{
+			name:     "torn last line",
+			journal:  putA + putB[:20],
+			want:     map[string]string{"a": "1"},
+			wantSize: len(putA),
+		},
+		{
+			name:     "torn last line is valid JSON",
+			journal:  putA + strings.TrimSuffix(putB, "\n"),
+			want:     map[string]string{"a": "1"},
+			wantSize: len(putA),
+		},
+		{
+			name:     "corrupt line in the middle",
+			journal:  putA + "{garbage\n" + putB,
+			want:     map[string]string{"a": "1", "b": "2"},
+			skipped:  1,
+			wantSize: len(putA + "{garbage\n" + putB),
+		},
+		{
+			name:     "unknown op in the middle",
+			journal:  putA + `{"op":"move","b":"b","k":"a"}` + "\n" + delA + putB,
+			want:     map[string]string{"b": "2"},
+			skipped:  1,
+			wantSize: len(putA+delA+putB) + len(`{"op":"move","b":"b","k":"a"}`+"\n"),
+		},
+		{
+			name:     "corrupt terminated last line",
+			journal:  putA + "\x00\x00\x00\n",
+			want:     map[string]string{"a": "1"},
+			skipped:  1,
+			wantSize: len(putA + "\x00\x00\x00\n"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+			err := ioutil.WriteFile(filepath.Join(dir, journalFile), []byte(tt.journal), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var skipped int
+			j, err := Open(dir, Options{ReplayError: func(error) { skipped++ }})
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer j.Close()
+			if got := contents(t, j); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("contents = %v, want %v", got, tt.want)
+			}
+			if skipped != tt.skipped {
+				t.Fatalf("skipped lines = %d, want %d", skipped, tt.skipped)
+			}
+			if j.Size() != int64(tt.wantSize) {
+				t.Fatalf("Size = %d, want %d", j.Size(), tt.wantSize)
+			}
+
+			// ghi tiep sau phan da cat, mo lai van doc duoc
+			err = j.Put("b", "c", 3)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			j.Close()
+			j, err = Open(dir, Options{})
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			defer j.Close()
+			if got := contents(t, j)["c"]; got != "3" {
+				t.Fatalf("c after reopen = %q, want 3", got)
+			}
+		})
+	}
+}
+
+// faultyFile : file journal ghi/fsync loi theo yeu cau
+type faultyFile struct {
+	*os.File
+	failWrite bool // ghi 1 nua dong roi bao loi
+	failSync  bool
+}
+
+var errInjected = errors.New("injected")
+
+func (f *faultyFile) Write(b []byte) (int, error) {
+	if f.failWrite {
+		n, _ := f.File.Write(b[:len(b)/2])
+		return n, errInjected
+	}
+	return f.File.Write(b)
+}
+
+func (f *faultyFile) Sync() error {
+	if f.failSync {
+		return errInjected
+	}
+	return f.File.Sync()
+}
+
+func TestAppendRollback(t *testing.T) {
+	tests := []struct {
+		name      string
+		failWrite bool
+		failSync  bool
+	}{
+		{"write", true, false},
+		{"fsync", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+			j, err := Open(dir, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = j.Put("b", "a", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size := j.Size()
+
+			f := &faultyFile{File: j.file.(*os.File), failWrite: tt.failWrite, failSync: tt.failSync}
+			j.file = f
+			err = j.Put("b", "b", 2)
+			if err != errInjected {
+				t.Fatalf("Put: err = %v, want injected error", err)
+			}
+			err = j.Delete("b", "a")
+			if err != errInjected {
+				t.Fatalf("Delete: err = %v, want injected error", err)
+			}
+			if got := contents(t, j); !reflect.DeepEqual(got, map[string]string{"a": "1"}) {
+				t.Fatalf("contents after failed writes = %v", got)
+			}
+			if j.Size() != size {
+				t.Fatalf("Size = %d, want %d", j.Size(), size)
+			}
+
+			// ghi lai duoc sau khi het loi, file khong co phan ghi do
+			f.failWrite, f.failSync = false, false
+			err = j.Put("b", "c", 3)
+			if err != nil {
+				t.Fatalf("Put after recovery: %v", err)
+			}
+			j.Close()
+			var skipped int
+			j, err = Open(dir, Options{ReplayError: func(error) { skipped++ }})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer j.Close()
+			want := map[string]string{"a": "1", "c": "3"}
+			if got := contents(t, j); !reflect.DeepEqual(got, want) || skipped != 0 {
+				t.Fatalf("contents after reopen = %v (skipped %d), want %v", got, skipped, want)
+			}
+		})
+	}
+}
+
+// TestCompactCrash : mat nguon sau khi doi ten snapshot nhung truoc khi cat journal,
+// journal cu duoc phat lai len snapshot moi
+func TestCompactCrash(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	j, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range []func() error{
+		func() error { return j.Put("b", "a", 1) },
+		func() error { return j.Put("b", "b", 2) },
+		func() error { return j.Delete("b", "a") },
+		func() error { return j.Put("b", "b", 3) },
+	} {
+		if err := op(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old, err := ioutil.ReadFile(filepath.Join(dir, journalFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = j.Compact()
+	if err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	j.Close()
+	if fi, err := os.Stat(filepath.Join(dir, journalFile)); err != nil || fi.Size() != 0 {
+		t.Fatalf("journal after Compact: %v, %v", fi, err)
+	}
+	// journal chua bi cat
+	err = ioutil.WriteFile(filepath.Join(dir, journalFile), old, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer j.Close()
+	want := map[string]string{"b": "3"}
+	if got := contents(t, j); !reflect.DeepEqual(got, want) {
+		t.Fatalf("contents = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile+".tmp")); !os.IsNotExist(err) {
+		t.Fatalf("temporary snapshot left behind: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Package store : kho trang thai cuc bo cua driver (key-value theo bucket, gia tri JSON),
+// giu lai qua cac lan khoi dong lai.
+//
+// Journal luu tren dia trong 1 thu muc:
+//
+//	state.json     : snapshot toan bo du lieu luc compact gan nhat
+//	state.journal  : moi dong 1 thay doi (put/del) dang JSON, ghi noi tiep sau snapshot
+//
+// Khi mo, snapshot duoc doc roi phat lai journal. Dong cuoi bi cat do mat nguon
+// giua luc ghi (chua co '\n') duoc bo qua va cat khoi file; dong hong o giua journal
+// chi bi bo qua, cac thay doi sau no van duoc phat lai. Compact ghi snapshot moi ra file tam,
+// fsync, doi ten de thay snapshot cu roi moi xoa journal, nen khong co thoi diem
+// nao du lieu da ghi chi nam tren file dang ghi do.
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrClosed : kho da dong
+var ErrClosed = errors.New("store: closed")
+
+// Store : kho key-value theo bucket, gia tri duoc ma hoa JSON
+type Store interface {
+	// Put : ghi gia tri (ma hoa JSON) cho key trong bucket
+	Put(bucket, key string, value interface{}) error
+	// Get : doc gia tri cua key vao value, false neu khong co
+	Get(bucket, key string, value interface{}) (bool, error)
+	// Delete : xoa key, khong loi neu key khong ton tai
+	Delete(bucket, key string) error
+	// ForEach : duyet cac key cua bucket theo thu tu tang dan
+	ForEach(bucket string, fn func(key string, value json.RawMessage) error) error
+	// Compact : gom du lieu thanh snapshot moi, xoa journal
+	Compact() error
+	Close() error
+}
+
+// data : du lieu trong bo nho, dung chung cho Memory va Journal
+type data struct {
+	mutex   sync.Mutex
+	closed  bool
+	buckets map[string]map[string]json.RawMessage
+}
+
+func newData() data {
+	return data{buckets: make(map[string]map[string]json.RawMessage)}
+}
+
+func (d *data) set(bucket, key string, value json.RawMessage) {
+	b, ok := d.buckets[bucket]
+	if !ok {
+		b = make(map[string]json.RawMessage)
+		d.buckets[bucket] = b
+	}
+	b[key] = value
+}
+
+func (d *data) unset(bucket, key string) {
+	b, ok := d.buckets[bucket]
+	if !ok {
+		return
+	}
+	delete(b, key)
+	if len(b) == 0 {
+		delete(d.buckets, bucket)
+	}
+}
+
+func (d *data) get(bucket, key string, value interface{}) (bool, error) {
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return false, ErrClosed
+	}
+	raw, ok := d.buckets[bucket][key]
+	d.mutex.Unlock()
+	if !ok {
+		return false, nil
+	}
+	// so trong interface{} giu dang json.Number nhu khi nhan tu frame
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	return true, decoder.Decode(value)
+}
+
+func (d *data) forEach(bucket string, fn func(key string, value json.RawMessage) error) error {
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return ErrClosed
+	}
+	b := d.buckets[bucket]
+	keys := make([]string, 0, len(b))
+	values := make(map[string]json.RawMessage, len(b))
+	for k, v := range b {
+		keys = append(keys, k)
+		values[k] = v
+	}
+	d.mutex.Unlock()
+
+	// fn duoc goi ngoai mutex, co the ghi lai vao kho
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Memory : kho chi trong bo nho, dung khi khong cau hinh thu muc luu tru
+type Memory struct {
+	data
+}
+
+// NewMemory : tao kho trong bo nho
+func NewMemory() *Memory {
+	return &Memory{data: newData()}
+}
+
+func (m *Memory) Put(bucket, key string, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.set(bucket, key, raw)
+	return nil
+}
+
+func (m *Memory) Get(bucket, key string, value interface{}) (bool, error) {
+	return m.get(bucket, key, value)
+}
+
+func (m *Memory) Delete(bucket, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.unset(bucket, key)
+	return nil
+}
+
+func (m *Memory) ForEach(bucket string, fn func(key string, value json.RawMessage) error) error {
+	return m.forEach(bucket, fn)
+}
+
+func (m *Memory) Compact() error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.closed = true
+	return nil
+}
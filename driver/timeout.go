@@ -0,0 +1,96 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/models"
+)
+
+// ten cac khoa cau hinh thoi gian cho (ms) trong muc [Driver]
+const (
+	nameSendTimeoutConfig      = "SendTimeout"      // cho cho trong cua so truyen va gui xong
+	nameResponseTimeoutConfig  = "ResponseTimeout"  // cho phan hoi cua lenh doc/ghi
+	nameProvisionTimeoutConfig = "ProvisionTimeout" // cho phan hoi cua yeu cau them doi tuong
+	nameRequestTimeoutConfig   = "RequestTimeout"   // tong thoi gian cua 1 yeu cau tu SDK, 0 = khong gioi han
+)
+
+// nameTimeoutProperty : thuoc tinh trong Network protocol, ghi de ResponseTimeout cho tung thiet bi (ms)
+const nameTimeoutProperty = "Timeout"
+
+const (
+	defaultSendTimeout      = 5000 * time.Millisecond
+	defaultResponseTimeout  = 5000 * time.Millisecond
+	defaultProvisionTimeout = 20000 * time.Millisecond
+)
+
+type timeoutConfig struct {
+	send      time.Duration
+	response  time.Duration
+	provision time.Duration
+	request   time.Duration
+}
+
+var (
+	timeoutMutex sync.Mutex
+	timeouts     = timeoutConfig{
+		send:      defaultSendTimeout,
+		response:  defaultResponseTimeout,
+		provision: defaultProvisionTimeout,
+	}
+)
+
+// initTimeouts : doc thoi gian cho mac dinh tu muc [Driver] cua configuration.toml
+func initTimeouts(config map[string]string) error {
+	t := timeoutConfig{
+		send:      defaultSendTimeout,
+		response:  defaultResponseTimeout,
+		provision: defaultProvisionTimeout,
+	}
+	items := []struct {
+		key string
+		val *time.Duration
+	}{
+		{nameSendTimeoutConfig, &t.send},
+		{nameResponseTimeoutConfig, &t.response},
+		{nameProvisionTimeoutConfig, &t.provision},
+		{nameRequestTimeoutConfig, &t.request},
+	}
+	for _, item := range items {
+		v, ok := configValue(config, item.key)
+		if !ok {
+			continue
+		}
+		ms, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || (ms == 0 && item.key != nameRequestTimeoutConfig) {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", item.key, v)
+		}
+		*item.val = time.Duration(ms) * time.Millisecond
+	}
+
+	timeoutMutex.Lock()
+	timeouts = t
+	timeoutMutex.Unlock()
+	return nil
+}
+
+func currentTimeouts() timeoutConfig {
+	timeoutMutex.Lock()
+	defer timeoutMutex.Unlock()
+	return timeouts
+}
+
+// responseTimeoutOf : thoi gian cho phan hoi cua thiet bi, uu tien thuoc tinh Timeout trong Network protocol
+func responseTimeoutOf(protocols map[string]models.ProtocolProperties) time.Duration {
+	if pp, ok := protocols[nameNetworkProtocol]; ok {
+		if v, ok := pp[nameTimeoutProperty]; ok {
+			ms, err := strconv.ParseUint(v, 10, 32)
+			if err == nil && ms > 0 {
+				return time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return currentTimeouts().response
+}
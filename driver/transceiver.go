@@ -1,184 +1,139 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"time"
 
-	"github.com/tarm/serial"
+	"github.com/device-zigbee/driver/capture"
+	"github.com/device-zigbee/driver/frame"
 )
 
 const sizeChannel = 1
 
 var chanSend chan bool
-var serialPort *serial.Port
+var transport Transport
 
 // TransceiverInit : duoc goi khi khoi tao DS
-func TransceiverInit(port string) (err error) {
+func TransceiverInit(t Transport) (err error) {
 	chanSend = make(chan bool, sizeChannel)
-	// setup uart
-	configSerial := &serial.Config{Name: port, Baud: 9600}
-	serialPort, err = serial.OpenPort(configSerial)
+	// setup transport
+	err = t.Open()
 	if err != nil {
 		return err
 	}
-	fmt.Println("Open Port successful")
+	transport = t
+	driver.Logger.Info("Open Transport successful")
 
-	go receiverUartRoutine()
+	link = newLinkSupervisor(t)
+	link.up()
 	return nil
 }
 
-// TransceiverClose : close serial
+// TransceiverClose : close transport, khong ket noi lai
 func TransceiverClose() {
-	serialPort.Close()
+	if link != nil {
+		link.close()
+	}
 }
 
-// SendUartPacket : (timeout - ms, timeUsed-ms) gui du lieu dang ContenRepo toi Uart
-func SendUartPacket(content ContentRepo, timeout int64) (timeUsed int64, err error) {
+// SendUartPacket : gui du lieu dang ContenRepo toi Uart trong cua so truyen cua dest, dung khi ctx ket thuc.
+// Cho trong cua so duoc giu toi khi goi release (sau khi nhan phan hoi); neu loi, cho da duoc giai phong
+func SendUartPacket(ctx context.Context, content ContentRepo, dest string) (release func(), err error) {
 	rawData, lenght, ok := convertStructToTXUartArray(content)
 	if !ok || lenght == 0 {
-		return timeout, fmt.Errorf("Loi: convert Struct to Uart bytes")
+		return nil, errEncodeFrame
 	}
 
 	if chanSend == nil {
-		return timeout, fmt.Errorf("Loi: kenh truyen chua duoc khoi tao hoac da bi dong")
+		// kenh truyen chua duoc khoi tao
+		return nil, ErrLinkDown
+	}
+	if !LinkIsUp() {
+		return nil, ErrLinkDown
 	}
 
-	timeOut := time.After(time.Duration(timeout) * time.Millisecond)
-	t0 := time.Now()
+	release, err = txWin().acquire(ctx, dest)
+	if err != nil {
+		return nil, err
+	}
 
 	select {
-	case <-timeOut:
-		err = fmt.Errorf("Loi: Timeout")
+	case <-ctx.Done():
+		err = contextError(ctx.Err())
 	case chanSend <- true:
+		// ghi thoi diem gui truoc khi ghi, phan hoi co the den truoc khi Write tra ve
+		seq := seqOf(content.Content)
+		pendingRequests().markSent(seq, time.Now())
 		chanSendErr := make(chan error, 1)
 		go sendUart(rawData, lenght, chanSendErr)
-		err, ok = <-chanSendErr
-	}
-	timeUsed = time.Since(t0).Nanoseconds() / 1000 // timeUsed: ms
-	return
-}
-
-// receiver []byte --> ContentRepo
-func receiverUartRoutine() {
-	var raw []byte
-	var l int16
-	for {
-		raw, l = receiverUart()
-		if l > 0 {
-			fmt.Println("rx:" + string(raw))
-			go sendRXUartArrayToRepo(raw, l)
+		err = <-chanSendErr
+		if err != nil {
+			pendingRequests().markSent(seq, time.Time{})
 		}
 	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
 }
 
-func sendUart(rawData []byte, lenght int16, chanSendErr chan error) {
-	fmt.Println("send raw data:", string(rawData), " - len=", lenght, "\n")
-	serialPort.Flush()
-	_, err := serialPort.Write(rawData)
-	<-chanSend
-	chanSendErr <- err
+// seqOf : seq cua frame gui di, 0 neu frame khong co seq
+func seqOf(content interface{}) uint8 {
+	switch c := content.(type) {
+	case CommandFrame:
+		return c.Seq
+	case ProvisonFrame:
+		return c.Seq
+	case DeleteObjectFrame:
+		return c.Seq
+	case ScanDeviceFrame:
+		return c.Seq
+	}
+	return 0
 }
 
-func receiverUart() ([]byte, int16) {
-	var n int
-	var err error
-	var raw []byte
-
-	header := make([]byte, 1)
-	lenghtBytes := make([]byte, 2)
-	var lenghtPayload int16
-	cmd := make([]byte, 1)
-	crc := make([]byte, 1)
-	var checkCRC byte
-	data := make([]byte, 1)
-
+// receiver UARTFrame --> ContentRepo, ket thuc khi ket noi the he gen bi loi
+func receiverUartRoutine(t Transport, gen int) {
+	decoder := frame.NewDecoder(countingReader{t}, 0)
+	var last frame.DecoderStats
 	for {
-		// receive Header 1 byte:
-		for {
-			n, err = serialPort.Read(header)
-			if err != nil {
-				return nil, 0
-			}
-			if n < 1 {
-				continue
-			}
-			if header[0] == headerConst {
-				break
-			}
-		}
-
-		// receive Lenght 2 byte
-		n, err = serialPort.Read(data)
-		if err != nil {
-			return nil, 0
-		}
-		if n < 1 {
-			continue
-		}
-		lenghtBytes[0] = data[0]
-
-		n, err = serialPort.Read(data)
+		rxFrame, err := decoder.Decode()
+		counters.addDecoderStats(decoder.Stats(), &last)
 		if err != nil {
-			return nil, 0
-		}
-		if n < 1 {
-			continue
-		}
-		lenghtBytes[1] = data[0]
-
-		lenghtPayload = (int16(lenghtBytes[0]) << 8) | int16(lenghtBytes[1])
-		if lenghtPayload <= 1 {
-			continue
-		}
-
-		// receive Cmd
-		n, err = serialPort.Read(cmd)
-		if err != nil {
-			return nil, 0
-		}
-		if n < 1 {
-			continue
-		}
-		// if !checkVaildCmd(int8(cmd[0])) {
-		// 	continue
-		// }
-
-		// reset checkCRC
-		checkCRC = headerConst + lenghtBytes[0] + lenghtBytes[1] + cmd[0]
-
-		// receive payload
-		payloadBytes := make([]byte, lenghtPayload-1)
-		for i := range payloadBytes {
-			n, err = serialPort.Read(data)
-			if err != nil || n < 1 {
-				return nil, 0
+			if frame.IsFrameError(err) {
+				if e, ok := err.(*frame.CRCError); ok {
+					captureFrame(capture.RX, e.Frame.Bytes())
+				}
+				counters.addFrameError(err)
+				driver.Logger.Debug(fmt.Sprintf("rx: bo frame loi: %v", err))
+				continue
 			}
-			payloadBytes[i] = data[0]
+			link.fail(gen, err)
+			return
 		}
-
-		// receive CRC
-		n, err = serialPort.Read(crc)
-		if err != nil {
-			return nil, 0
-		}
-		if n < 1 {
-			continue
-		}
-		// caculate CRC:
-		for _, c := range payloadBytes {
-			checkCRC += c
-		}
-		if checkCRC != crc[0] {
-			continue
-		}
-		raw = make([]byte, 0, lenghtPayload+4)
-		raw = append(raw, header[0])
-		raw = append(raw, lenghtBytes...)
-		raw = append(raw, cmd[0])
-		raw = append(raw, payloadBytes...)
-		raw = append(raw, crc[0])
-		break
+		counters.update(func(s *LinkStats) { s.FramesReceived++ })
+		captureFrame(capture.RX, rxFrame.Bytes())
+		driver.Logger.Debug("rx: " + string(rxFrame.Payload))
+		go sendRXUartFrameToRepo(rxFrame)
 	}
+}
 
-	return raw, int16(lenghtPayload + 4)
+func sendUart(rawData []byte, lenght int16, chanSendErr chan error) {
+	driver.Logger.Debug(fmt.Sprintf("tx: %s - len=%d", rawData, lenght))
+	gen := link.generation()
+	_, err := transport.Write(rawData)
+	if err != nil {
+		link.fail(gen, err)
+		err = ErrLinkDown
+	} else {
+		counters.update(func(s *LinkStats) {
+			s.FramesSent++
+			s.BytesSent += uint64(len(rawData))
+		})
+		captureFrame(capture.TX, rawData)
+	}
+	<-chanSend
+	chanSendErr <- err
 }
@@ -0,0 +1,236 @@
+package driver
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// ten cac khoa cau hinh trong muc [Driver] cua configuration.toml
+const (
+	nameTransportConfig  = "Transport"
+	nameSerialPortConfig = "SerialPort"
+	nameTCPAddressConfig = "TCPAddress"
+)
+
+// cac loai Transport ho tro
+const (
+	serialTransportType = "serial"
+	tcpTransportType    = "tcp"
+	pipeTransportType   = "pipe"
+)
+
+const defaultDialTimeout = 5 * time.Second
+
+// Transport : kenh truyen byte toi coordinator (serial, TCP, pipe trong bo nho)
+type Transport interface {
+	Open() error
+	Read(p []byte) (n int, err error)
+	Write(p []byte) (n int, err error)
+	Close() error
+}
+
+// newTransportFromConfig : tao Transport theo muc [Driver] cua configuration.toml
+func newTransportFromConfig(config map[string]string) (Transport, error) {
+	transportType := strings.ToLower(strings.TrimSpace(config[nameTransportConfig]))
+	switch transportType {
+	case "", serialTransportType:
+		line, err := parseSerialLineConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return newSerialTransportWithFlow(&line.Config, line.FlowControl), nil
+	case tcpTransportType:
+		address, ok := config[nameTCPAddressConfig]
+		if !ok || address == "" {
+			return nil, fmt.Errorf("Khong chi dinh TCPAddress")
+		}
+		return NewTCPTransport(address), nil
+	case pipeTransportType:
+		return NewPipeTransport(), nil
+	}
+	return nil, fmt.Errorf("Khong ho tro Transport: %s", transportType)
+}
+
+//------------------------------ Serial Transport ----------------------------
+
+type serialTransport struct {
+	config *serial.Config
+	flow   string
+	port   *serial.Port
+}
+
+// NewSerialTransport : Transport qua cong serial (tarm/serial)
+func NewSerialTransport(config *serial.Config) Transport {
+	return newSerialTransportWithFlow(config, flowControlNone)
+}
+
+func newSerialTransportWithFlow(config *serial.Config, flow string) Transport {
+	return &serialTransport{config: config, flow: flow}
+}
+
+func (s *serialTransport) Open() (err error) {
+	s.port, err = serial.OpenPort(s.config)
+	if err != nil {
+		return fmt.Errorf("Khong mo duoc cong %s (Baud=%d): %v", s.config.Name, s.config.Baud, err)
+	}
+	err = setFlowControl(s.config.Name, s.flow)
+	if err != nil {
+		s.port.Close()
+		return fmt.Errorf("Khong cai dat duoc FlowControl %s cho cong %s: %v", s.flow, s.config.Name, err)
+	}
+	return nil
+}
+
+func (s *serialTransport) Read(p []byte) (int, error) {
+	if s.port == nil {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := s.port.Read(p)
+	if n == 0 && err == io.EOF && s.config.ReadTimeout > 0 {
+		// het ReadTimeout ma khong co du lieu, khong phai loi
+		return 0, nil
+	}
+	return n, err
+}
+
+func (s *serialTransport) Write(p []byte) (int, error) {
+	if s.port == nil {
+		return 0, io.ErrClosedPipe
+	}
+	// bo du lieu cu con trong bo dem truoc khi gui
+	s.port.Flush()
+	return s.port.Write(p)
+}
+
+func (s *serialTransport) Close() error {
+	if s.port == nil {
+		return nil
+	}
+	return s.port.Close()
+}
+
+//-------------------------------- TCP Transport -----------------------------
+
+type tcpTransport struct {
+	address string
+	conn    net.Conn
+}
+
+// NewTCPTransport : Transport qua TCP, dung cho coordinator noi mang (ser2net, ...)
+func NewTCPTransport(address string) Transport {
+	return &tcpTransport{address: address}
+}
+
+func (t *tcpTransport) Open() (err error) {
+	t.conn, err = net.DialTimeout("tcp", t.address, defaultDialTimeout)
+	return err
+}
+
+func (t *tcpTransport) Read(p []byte) (int, error) {
+	if t.conn == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return t.conn.Read(p)
+}
+
+func (t *tcpTransport) Write(p []byte) (int, error) {
+	if t.conn == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return t.conn.Write(p)
+}
+
+func (t *tcpTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
+
+//------------------------------- Pipe Transport -----------------------------
+
+// PipeTransport : Transport trong bo nho, dau con lai (Peer) dong vai tro coordinator
+type PipeTransport struct {
+	mutex sync.Mutex
+	local net.Conn
+	peer  net.Conn
+}
+
+var (
+	pipeMutex   sync.Mutex
+	currentPipe *PipeTransport
+)
+
+// NewPipeTransport : tao PipeTransport, co the lay lai bang CurrentPipeTransport()
+func NewPipeTransport() *PipeTransport {
+	p := new(PipeTransport)
+	pipeMutex.Lock()
+	currentPipe = p
+	pipeMutex.Unlock()
+	return p
+}
+
+// CurrentPipeTransport : PipeTransport duoc tao gan nhat (Transport = "pipe")
+func CurrentPipeTransport() (*PipeTransport, bool) {
+	pipeMutex.Lock()
+	defer pipeMutex.Unlock()
+	return currentPipe, currentPipe != nil
+}
+
+// Open : tao cap ket noi moi neu chua mo
+func (p *PipeTransport) Open() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.local == nil {
+		p.local, p.peer = net.Pipe()
+	}
+	return nil
+}
+
+// Peer : dau ket noi phia coordinator, nil neu chua Open
+func (p *PipeTransport) Peer() io.ReadWriteCloser {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.peer
+}
+
+func (p *PipeTransport) conn() net.Conn {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.local
+}
+
+func (p *PipeTransport) Read(b []byte) (int, error) {
+	c := p.conn()
+	if c == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return c.Read(b)
+}
+
+func (p *PipeTransport) Write(b []byte) (int, error) {
+	c := p.conn()
+	if c == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return c.Write(b)
+}
+
+// Close : dong ca hai dau ket noi
+func (p *PipeTransport) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.local == nil {
+		return nil
+	}
+	p.local.Close()
+	p.peer.Close()
+	p.local, p.peer = nil, nil
+	return nil
+}
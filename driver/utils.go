@@ -1,16 +1,16 @@
 package driver
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/device-zigbee/driver/frame"
 	"github.com/device-zigbee/driver/packet"
 )
 
-const headerConst = 0x55
-
 const (
 	//CommandCmdConst :
 	CommandCmdConst = iota
@@ -26,6 +26,9 @@ const (
 
 	//ScanCmdConst :
 	ScanCmdConst
+
+	//DeviceAnnounceCmdConst : thiet bi tham gia/tham gia lai mang (Zigbee device announce)
+	DeviceAnnounceCmdConst
 )
 
 const (
@@ -38,14 +41,8 @@ const (
 //-------------------------------- Packet Struct -----------------------------------
 //------------------------------ Common Frame ----------------------------
 
-// UARTFrame :	 TX-RX UART frame
-type UARTFrame struct {
-	Header  byte
-	Lenght  int16 // Lenght = len(Payload) + size(Cmd)
-	Cmd     byte
-	Payload []byte
-	CRC     byte
-}
+// UARTFrame :	 TX-RX UART frame, dong goi/tach boi package frame
+type UARTFrame = frame.UARTFrame
 
 // ContentRepo :	RX_UART EdgeX --> Repo
 type ContentRepo struct {
@@ -56,7 +53,8 @@ type ContentRepo struct {
 // ResponseCommonFrame :	Repo --> EdgeX
 type ResponseCommonFrame struct {
 	ObjectInfo
-	StatusResponse uint8  `json:"resp"` // tru Push event se khong co StatusResponse
+	Seq            uint8  `json:"seq,omitempty"` // seq cua yeu cau, firmware cu khong tra lai
+	StatusResponse uint8  `json:"resp"`          // tru Push event se khong co StatusResponse
 	NameDevice     string `json:"name,omitempty"`
 	Description    string `json:"desc,omitempty"`
 	AttributeValue
@@ -67,7 +65,8 @@ type ResponseCommonFrame struct {
 // CommandFrame :	EdgeX --> Zigbee
 type CommandFrame struct {
 	ObjectAddress
-	CommandID int8 `json:"cmid"` // Get = 0x01, Set = 0x02, Delete = 0x03
+	Seq       uint8 `json:"seq,omitempty"`
+	CommandID int8  `json:"cmid"` // Get = 0x01, Set = 0x02, Delete = 0x03
 	AttributeInfo
 	Value interface{} `json:"val,omitempty"`
 }
@@ -77,95 +76,114 @@ type CommandFrame struct {
 // ProvisonFrame :	EdgeX --> Zigbee
 type ProvisonFrame struct {
 	AddressEUI64
+	Seq        uint8  `json:"seq,omitempty"`
 	NameDevice string `json:"name,omitempty"`
 }
 
 // DeleteObjectFrame :	EdgeX --> Zigbee
 type DeleteObjectFrame struct {
 	ObjectAddress
+	Seq   uint8 `json:"seq,omitempty"`
+	Leave bool  `json:"leave,omitempty"` // gui Zigbee leave request toi thiet bi truoc khi xoa
 }
 
 //-------------------------- Cmd {scan device} ---------------------------
 
 // ScanDeviceFrame :	EdgeX --> Zigbee
 type ScanDeviceFrame struct {
-	ScanTime int8 `json:"scan"`
+	Seq      uint8 `json:"seq,omitempty"`
+	ScanTime int8  `json:"scan"` // thoi gian mo permit-join (s)
 }
 
 //----------------------------------------------------------------------------------
 
 func checkVaildCmd(cmd int8) bool {
 	if (cmd == CommandCmdConst) || (cmd == AddObjectCmdConst) || (cmd == PushEventCmdConst) ||
-		(cmd == DeleteObjectCmdConst) || (cmd == ScanCmdConst) {
+		(cmd == DeleteObjectCmdConst) || (cmd == ScanCmdConst) || (cmd == DeviceAnnounceCmdConst) {
 		return true
 	}
 	return false
 }
 
-// ConvertUARTFrameToContentRepo : convert bytes received from UARTFrame to ContentRepo
-// su dung truoc khi dua vao Repo
-func convertUARTFrameToContentRepo(bFrame []byte, lenFrame int16) (nameRepo string, result ContentRepo, ok bool) {
-	var rxFrame UARTFrame
-
-	rxFrame.Header = bFrame[0]
-	rxFrame.Lenght = (int16(bFrame[1]) << 8) | int16(bFrame[2])
-	rxFrame.Cmd = bFrame[3]
-	rxFrame.Payload = make([]byte, 0, lenFrame-5)
-	rxFrame.Payload = bFrame[4 : lenFrame-1]
-	rxFrame.CRC = bFrame[lenFrame-1]
-
+// ConvertUARTFrameToContentRepo : convert UARTFrame received to ContentRepo
+// su dung truoc khi dua vao Repo. seq = 0 nghia la frame khong phai phan hoi (push event, device announce)
+func convertUARTFrameToContentRepo(rxFrame UARTFrame) (seq uint8, result ContentRepo, ok bool) {
 	result.Cmd = int8(rxFrame.Cmd)
 
 	if checkVaildCmd(result.Cmd) == false {
-		// fmt.Println("utils 120")
-		return "", ContentRepo{}, false
+		counters.update(func(s *LinkStats) { s.UnknownCmd++ })
+		return 0, ContentRepo{}, false
 	}
-	var content ResponseCommonFrame
-	err := json.Unmarshal(rxFrame.Payload, &content)
+	content, err := decodeResponseFrame(rxFrame.Payload)
 	if err != nil {
-		// fmt.Println("utils 126")
-		return "", ContentRepo{}, false
+		counters.update(func(s *LinkStats) { s.JSONErrors++ })
+		return 0, ContentRepo{}, false
 	}
 	result.Content = interface{}(content)
 
+	if content.Seq != 0 && result.Cmd != PushEventCmdConst && result.Cmd != DeviceAnnounceCmdConst {
+		// phan hoi co seq chi duoc gui toi dung yeu cau da gui no
+		if !pendingRequests().lookupBySeq(content.Seq) {
+			counters.update(func(s *LinkStats) { s.UnmatchedFrames++ })
+			return 0, ContentRepo{}, false
+		}
+		return content.Seq, result, true
+	}
+
+	var key string
 	switch result.Cmd {
 	case CommandCmdConst:
 		obAddr := content.ObjectAddress
 		id, ok := Cache().ConvertAddrToIDObject(obAddr)
 		if !ok {
-			// fmt.Println("utils 136: %+v", obAddr)
-			return "", ContentRepo{}, false
+			counters.update(func(s *LinkStats) { s.UnknownAddress++ })
+			return 0, ContentRepo{}, false
 		}
-		nameRepo = packet.Repo().GetRepoNameByID(id)
+		key = packet.Repo().GetRepoNameByID(id)
 
 	case PushEventCmdConst:
 		go PushEventGoroutine(content)
-		return "", result, true
+		return 0, result, true
+
+	case DeviceAnnounceCmdConst:
+		go deviceAnnounceGoroutine(content)
+		return 0, result, true
 
 	default:
-		nameRepo = packet.Repo().GetRepoNameByCMD(result.Cmd)
+		key = packet.Repo().GetRepoNameByCMD(result.Cmd)
 	}
-	return nameRepo, result, true
+	// firmware khong tra lai seq: gui toi yeu cau cu nhat dang cho theo ID/CMD
+	seq, found := pendingRequests().lookupByKey(key)
+	if !found {
+		counters.update(func(s *LinkStats) { s.UnmatchedFrames++ })
+		driver.Logger.Debug(fmt.Sprintf("Khong co yeu cau nao cho phan hoi %s, bo qua", key))
+		return 0, ContentRepo{}, false
+	}
+	return seq, result, true
 }
 
-// SendRXUartArrayToRepo : gui UARTFrame dang []byte da nhan toi Repo phu hop
+// decodeResponseFrame : payload JSON cua frame nhan duoc -> ResponseCommonFrame.
+// So trong "val" giu dang json.Number, float64 lam sai so nguyen lon hon 2^53
+func decodeResponseFrame(payload []byte) (ResponseCommonFrame, error) {
+	var content ResponseCommonFrame
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	err := decoder.Decode(&content)
+	return content, err
+}
+
+// SendRXUartFrameToRepo : gui UARTFrame da nhan toi Repo phu hop
 // su dung boi: RecieveUART co the dung no nhu 1 goroutine de gui du lieu da duoc xu ly toi:
 // CommandHandler(), Callback(), Push() goroutine, Discovery() goroutine
-func sendRXUartArrayToRepo(bFrameIn []byte, lenght int16) {
-	bFrame := make([]byte, 0, lenght)
-	bFrame = append(bFrame, bFrameIn...)
-
-	nameRepo, content, ok := convertUARTFrameToContentRepo(bFrame, lenght)
-	if !ok {
-		// fmt.Println("utils 157")
+func sendRXUartFrameToRepo(rxFrame UARTFrame) {
+	seq, content, ok := convertUARTFrameToContentRepo(rxFrame)
+	if !ok || seq == 0 {
 		return
 	}
-	if nameRepo == "" {
-		// fmt.Println("utils 161")
-		return
+	if !pendingRequests().deliver(seq, content.Content) {
+		counters.update(func(s *LinkStats) { s.UnmatchedFrames++ })
+		driver.Logger.Debug(fmt.Sprintf("Yeu cau seq=%d khong con cho phan hoi, bo qua", seq))
 	}
-	packet.Repo().SendToRepo(nameRepo, content)
-	// fmt.Printf("utils 163: send to repo:%s-content:%+v", nameRepo, content)
 }
 
 func serialJson(content ContentRepo) ([]byte, error) {
@@ -181,7 +199,7 @@ func serialJson(content ContentRepo) ([]byte, error) {
 
 	var commandFrame CommandFrame
 	_ = json.Unmarshal(payload, &commandFrame)
-	if (commandFrame.AttributeInfo == managerSubcribeAttInfo) || (commandFrame.AttributeInfo == managerSubcribeAttInfo) {
+	if (commandFrame.AttributeInfo == managerSubcribeAttInfo) || (commandFrame.AttributeInfo == mangerScheduleAttInfo) {
 		// fmt.Println("utils 179")
 		istart := strings.Index(string(payload), "val\":\"") + 6
 		iend := strings.LastIndex(string(payload), "\"")
@@ -211,7 +229,6 @@ func serialJson(content ContentRepo) ([]byte, error) {
 // su dung de: chuyen content nhan tu channel ContentRepo cua SendUART() thanh []byte de gui UART
 // su dung boi: SendUART() goroutine
 func convertStructToTXUartArray(content ContentRepo) ([]byte, int16, bool) {
-	var frame UARTFrame
 	payload, err := json.Marshal(&content.Content)
 	if err != nil {
 		return nil, 0, false
@@ -221,27 +238,11 @@ func convertStructToTXUartArray(content ContentRepo) ([]byte, int16, bool) {
 	// 	return nil, 0, false
 	// }
 
-	frame.Header = headerConst
-	frame.Cmd = byte(content.Cmd)
-	frame.Payload = payload
-	frame.Lenght = int16(len(payload) + 1) // 1 = size(cmd)
-	// caculate CRC
-	frame.CRC = headerConst + byte((frame.Lenght >> 8)) + byte((frame.Lenght & 0x00FF)) + frame.Cmd
-	for _, b := range payload {
-		frame.CRC += b
+	bFrame, err := frame.Encode(frame.New(byte(content.Cmd), payload))
+	if err != nil {
+		return nil, 0, false
 	}
-
-	hightLenght := byte(frame.Lenght >> 8)
-	lowLenght := byte(frame.Lenght & 0x00FF)
-	bFrame := make([]byte, 0, frame.Lenght+4) // 4 = Header + Lenght + CRC
-
-	bFrame = append(bFrame, frame.Header)
-	bFrame = append(bFrame, hightLenght)
-	bFrame = append(bFrame, lowLenght)
-	bFrame = append(bFrame, frame.Cmd)
-	bFrame = append(bFrame, frame.Payload...)
-	bFrame = append(bFrame, frame.CRC)
-	return bFrame, (frame.Lenght + 4), true
+	return bFrame, int16(len(bFrame)), true
 }
 
 type labelsType []string
@@ -267,16 +268,16 @@ func (l labelsType) isInitializied() bool {
 	return false
 }
 
-func (l labelsType) setInitializied() {
+// setInitializied : tra ve labels co nhan initializied, thay the nhan uninitializied (neu co)
+func (l labelsType) setInitializied() labelsType {
 	for i, label := range l {
 		if label == INITIALIZIED {
-			return
+			return l
 		}
 		if label == UNINITIALIZIED {
 			l[i] = INITIALIZIED
-			return
+			return l
 		}
 	}
-	l = append(l, INITIALIZIED)
-	return
+	return append(l, INITIALIZIED)
 }
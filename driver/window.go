@@ -0,0 +1,216 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// ten cac khoa cau hinh cua cua so truyen trong muc [Driver]
+const (
+	nameMaxOutstandingConfig          = "MaxOutstanding"          // so yeu cau dang cho phan hoi toi da cua coordinator
+	nameMaxOutstandingPerDeviceConfig = "MaxOutstandingPerDevice" // so yeu cau dang cho phan hoi toi da cua moi dia chi
+)
+
+const (
+	defaultMaxOutstanding          = 8
+	defaultMaxOutstandingPerDevice = 1
+)
+
+// txWindow : gioi han so yeu cau dang cho phan hoi, chia luot cong bang giua cac dia chi dich
+type txWindow struct {
+	mutex      sync.Mutex
+	max        int
+	maxPerDest int
+	active     int
+	activeDest map[string]int
+	queues     map[string][]chan struct{}
+	ring       []string // cac dia chi co yeu cau dang xep hang, phuc vu lan luot
+	next       int
+}
+
+var (
+	txwMutex sync.Mutex
+	txw      *txWindow
+)
+
+func newTxWindow(max int, maxPerDest int) *txWindow {
+	return &txWindow{
+		max:        max,
+		maxPerDest: maxPerDest,
+		activeDest: make(map[string]int),
+		queues:     make(map[string][]chan struct{}),
+	}
+}
+
+// initTxWindow : tao cua so truyen theo muc [Driver] cua configuration.toml
+func initTxWindow(config map[string]string) error {
+	max := defaultMaxOutstanding
+	maxPerDest := defaultMaxOutstandingPerDevice
+	if v, ok := configValue(config, nameMaxOutstandingConfig); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameMaxOutstandingConfig, v)
+		}
+		max = n
+	}
+	if v, ok := configValue(config, nameMaxOutstandingPerDeviceConfig); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameMaxOutstandingPerDeviceConfig, v)
+		}
+		maxPerDest = n
+	}
+	if maxPerDest > max {
+		maxPerDest = max
+	}
+
+	txwMutex.Lock()
+	txw = newTxWindow(max, maxPerDest)
+	txwMutex.Unlock()
+	return nil
+}
+
+func txWin() *txWindow {
+	txwMutex.Lock()
+	defer txwMutex.Unlock()
+	if txw == nil {
+		txw = newTxWindow(defaultMaxOutstanding, defaultMaxOutstandingPerDevice)
+	}
+	return txw
+}
+
+// acquire : cho den khi co cho trong cua so cho dest hoac ctx ket thuc, tra ve ham giai phong cho
+func (w *txWindow) acquire(ctx context.Context, dest string) (release func(), err error) {
+	w.mutex.Lock()
+	if w.active < w.max && w.activeDest[dest] < w.maxPerDest && len(w.queues[dest]) == 0 {
+		w.grantWithoutSync(dest)
+		w.mutex.Unlock()
+		return w.releaseFunc(dest), nil
+	}
+	ticket := make(chan struct{})
+	if len(w.queues[dest]) == 0 {
+		w.ring = append(w.ring, dest)
+	}
+	w.queues[dest] = append(w.queues[dest], ticket)
+	w.mutex.Unlock()
+
+	select {
+	case <-ticket:
+		return w.releaseFunc(dest), nil
+	case <-ctx.Done():
+	}
+
+	w.mutex.Lock()
+	removed := w.removeTicketWithoutSync(dest, ticket)
+	w.mutex.Unlock()
+	if !removed {
+		// da duoc cap cho dung luc het han
+		w.releaseFunc(dest)()
+	}
+	return nil, contextError(ctx.Err())
+}
+
+func (w *txWindow) releaseFunc(dest string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.mutex.Lock()
+			w.active--
+			w.activeDest[dest]--
+			if w.activeDest[dest] <= 0 {
+				delete(w.activeDest, dest)
+			}
+			w.dispatchWithoutSync()
+			w.mutex.Unlock()
+		})
+	}
+}
+
+func (w *txWindow) grantWithoutSync(dest string) {
+	w.active++
+	w.activeDest[dest]++
+}
+
+// dispatchWithoutSync : cap cho trong cho cac dia chi dang xep hang theo vong tron
+func (w *txWindow) dispatchWithoutSync() {
+	for w.active < w.max && len(w.ring) > 0 {
+		granted := false
+		for i := 0; i < len(w.ring); i++ {
+			idx := (w.next + i) % len(w.ring)
+			dest := w.ring[idx]
+			if w.activeDest[dest] >= w.maxPerDest {
+				continue
+			}
+			q := w.queues[dest]
+			ticket := q[0]
+			w.grantWithoutSync(dest)
+			close(ticket)
+			if len(q) == 1 {
+				delete(w.queues, dest)
+				w.ring = append(w.ring[:idx], w.ring[idx+1:]...)
+				w.next = idx
+			} else {
+				w.queues[dest] = q[1:]
+				w.next = idx + 1
+			}
+			if len(w.ring) > 0 {
+				w.next %= len(w.ring)
+			} else {
+				w.next = 0
+			}
+			granted = true
+			break
+		}
+		if !granted {
+			return
+		}
+	}
+}
+
+func (w *txWindow) removeTicketWithoutSync(dest string, ticket chan struct{}) bool {
+	q := w.queues[dest]
+	for i, t := range q {
+		if t != ticket {
+			continue
+		}
+		q = append(q[:i], q[i+1:]...)
+		if len(q) > 0 {
+			w.queues[dest] = q
+			return true
+		}
+		delete(w.queues, dest)
+		for j, d := range w.ring {
+			if d == dest {
+				w.ring = append(w.ring[:j], w.ring[j+1:]...)
+				if w.next > j {
+					w.next--
+				}
+				break
+			}
+		}
+		if len(w.ring) > 0 {
+			w.next %= len(w.ring)
+		} else {
+			w.next = 0
+		}
+		return true
+	}
+	return false
+}
+
+// destinationOfAddress : khoa cua so truyen theo dia chi mang cua doi tuong
+func destinationOfAddress(addr ObjectAddress) string {
+	return "addr:" + strconv.FormatUint(uint64(addr.Address), 10)
+}
+
+// usage : so cho dang dung va so yeu cau dang xep hang cho cho
+func (w *txWindow) usage() (active int, queued int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, q := range w.queues {
+		queued += len(q)
+	}
+	return w.active, queued
+}
@@ -0,0 +1,387 @@
+package zcl
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// Epoch : moc cua kieu UTC time
+var Epoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	maxShortString = 0xFE   // 0xFF la gia tri khong hop le cua byte do dai
+	maxLongString  = 0xFFFE // 0xFFFF la gia tri khong hop le cua 2 byte do dai
+	invalidUTC     = 0xFFFFFFFF
+)
+
+// IEEE : dia chi IEEE (EUI-64)
+type IEEE uint64
+
+func (a IEEE) String() string {
+	return fmt.Sprintf("%016X", uint64(a))
+}
+
+// ParseIEEE : 16 ky tu hex, cho phep dau ':' hoac '-' ngan cach
+func ParseIEEE(s string) (IEEE, error) {
+	s = strings.NewReplacer(":", "", "-", "").Replace(strings.TrimPrefix(strings.ToLower(s), "0x"))
+	if len(s) == 0 || len(s) > 16 {
+		return 0, fmt.Errorf("zcl: invalid IEEE address %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("zcl: invalid IEEE address %q", s)
+	}
+	return IEEE(v), nil
+}
+
+// RangeError : gia tri nam ngoai mien cua kieu
+type RangeError struct {
+	Type  DataType
+	Value interface{}
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("zcl: value %v out of range for %s", e.Value, e.Type)
+}
+
+// TypeError : gia tri khong chuyen duoc sang kieu
+type TypeError struct {
+	Type  DataType
+	Value interface{}
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("zcl: cannot convert %T %v to %s", e.Value, e.Value, e.Type)
+}
+
+// Decode : gia tri tho tren frame -> gia tri Go:
+// bool, uint64, int64, float32 (semi, single), float64, []byte, string, time.Time, IEEE
+func Decode(t DataType, raw interface{}) (interface{}, error) {
+	size := t.Size()
+	switch t.Kind() {
+	case KindBool:
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		}
+		u, err := toUint(t, raw)
+		if err != nil {
+			return nil, err
+		}
+		if u > 1 {
+			return nil, &RangeError{t, raw}
+		}
+		return u == 1, nil
+
+	case KindUnsigned:
+		u, err := toUint(t, raw)
+		if err != nil {
+			return nil, err
+		}
+		if u > maxUnsigned(size) {
+			return nil, &RangeError{t, raw}
+		}
+		return u, nil
+
+	case KindSigned:
+		if i, ok := negative(raw); ok {
+			if i < minSigned(size) {
+				return nil, &RangeError{t, raw}
+			}
+			return i, nil
+		}
+		u, err := toUint(t, raw)
+		if err != nil {
+			return nil, err
+		}
+		if u > maxUnsigned(size) {
+			return nil, &RangeError{t, raw}
+		}
+		return signExtend(u, size), nil
+
+	case KindFloat:
+		if t == SemiFloat {
+			u, err := toUint(t, raw)
+			if err != nil {
+				return nil, err
+			}
+			if u > math.MaxUint16 {
+				return nil, &RangeError{t, raw}
+			}
+			return halfToFloat32(uint16(u)), nil
+		}
+		f, err := toFloat(raw)
+		if err != nil {
+			return nil, &TypeError{t, raw}
+		}
+		if t == SingleFloat {
+			if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+				return nil, &RangeError{t, raw}
+			}
+			return float32(f), nil
+		}
+		return f, nil
+
+	case KindOctets:
+		return decodeString(t, raw)
+
+	case KindString:
+		b, err := decodeString(t, raw)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+
+	case KindTime:
+		u, err := toUint(t, raw)
+		if err != nil {
+			return nil, err
+		}
+		if u >= invalidUTC {
+			return nil, &RangeError{t, raw}
+		}
+		return Epoch.Add(time.Duration(u) * time.Second), nil
+
+	case KindIEEE:
+		if s, ok := raw.(string); ok {
+			return ParseIEEE(s)
+		}
+		u, err := toUint(t, raw)
+		if err != nil {
+			return nil, err
+		}
+		return IEEE(u), nil
+	}
+	return nil, fmt.Errorf("zcl: unsupported data type %s", t)
+}
+
+// Encode : gia tri Go (tu CommandValue) -> gia tri tho gui tren frame, kiem tra mien gia tri
+func Encode(t DataType, v interface{}) (interface{}, error) {
+	size := t.Size()
+	switch t.Kind() {
+	case KindBool:
+		b, err := cast.ToBoolE(v)
+		if err != nil {
+			return nil, &TypeError{t, v}
+		}
+		return b, nil
+
+	case KindUnsigned:
+		if _, neg := negative(v); neg {
+			return nil, &RangeError{t, v}
+		}
+		u, err := toUint(t, v)
+		if err != nil {
+			return nil, err
+		}
+		if u > maxUnsigned(size) {
+			return nil, &RangeError{t, v}
+		}
+		return u, nil
+
+	case KindSigned:
+		if n, ok := v.(json.Number); ok {
+			v = n.String()
+		}
+		i, err := cast.ToInt64E(v)
+		if err != nil {
+			return nil, &TypeError{t, v}
+		}
+		if u, ok := v.(uint64); ok && u > math.MaxInt64 {
+			return nil, &RangeError{t, v}
+		}
+		if i < minSigned(size) || i > maxSigned(size) {
+			return nil, &RangeError{t, v}
+		}
+		return i, nil
+
+	case KindFloat:
+		f, err := toFloat(v)
+		if err != nil {
+			return nil, &TypeError{t, v}
+		}
+		switch t {
+		case SemiFloat:
+			if math.Abs(f) > 65504 && !math.IsInf(f, 0) {
+				return nil, &RangeError{t, v}
+			}
+			return float32ToHalf(float32(f)), nil
+		case SingleFloat:
+			if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+				return nil, &RangeError{t, v}
+			}
+			return float32(f), nil
+		}
+		return f, nil
+
+	case KindOctets, KindString:
+		b, err := EncodeString(t, v)
+		if err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(b), nil
+
+	case KindTime:
+		var tm time.Time
+		switch x := v.(type) {
+		case time.Time:
+			tm = x
+		case string:
+			var err error
+			tm, err = time.Parse(time.RFC3339, x)
+			if err != nil {
+				return nil, &TypeError{t, v}
+			}
+		default:
+			sec, err := cast.ToInt64E(v)
+			if err != nil {
+				return nil, &TypeError{t, v}
+			}
+			tm = time.Unix(sec, 0)
+		}
+		if tm.Before(Epoch) {
+			return nil, &RangeError{t, v}
+		}
+		sec := uint64(tm.Sub(Epoch) / time.Second)
+		if sec >= invalidUTC {
+			return nil, &RangeError{t, v}
+		}
+		return sec, nil
+
+	case KindIEEE:
+		switch x := v.(type) {
+		case IEEE:
+			return x.String(), nil
+		case string:
+			a, err := ParseIEEE(x)
+			if err != nil {
+				return nil, err
+			}
+			return a.String(), nil
+		}
+		u, err := toUint(t, v)
+		if err != nil {
+			return nil, err
+		}
+		return IEEE(u).String(), nil
+	}
+	return nil, fmt.Errorf("zcl: unsupported data type %s", t)
+}
+
+// toUint : so nguyen khong am tu gia tri JSON (json.Number, float64), so nguyen Go, bool hoac chuoi so
+func toUint(t DataType, v interface{}) (uint64, error) {
+	switch x := v.(type) {
+	case json.Number:
+		if u, err := strconv.ParseUint(x.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		// "1.0", "1e3", so am
+		f, err := x.Float64()
+		if err != nil {
+			return 0, &TypeError{t, v}
+		}
+		if _, err := strconv.ParseInt(x.String(), 10, 64); err == nil || f > 1<<53 {
+			// so nguyen am, hoac so thuc qua lon de chuyen chinh xac
+			return 0, &RangeError{t, v}
+		}
+		return toUint(t, f)
+	case float64:
+		if x < 0 || x != math.Trunc(x) || x >= 1<<64 {
+			return 0, &RangeError{t, v}
+		}
+		return uint64(x), nil
+	case float32:
+		return toUint(t, float64(x))
+	case uint64:
+		return x, nil
+	case uint:
+		return uint64(x), nil
+	case uint8, uint16, uint32:
+		return cast.ToUint64(x), nil
+	case int, int8, int16, int32, int64:
+		i := cast.ToInt64(x)
+		if i < 0 {
+			return 0, &RangeError{t, v}
+		}
+		return uint64(i), nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		u, err := strconv.ParseUint(x, 0, 64)
+		if err != nil {
+			return 0, &TypeError{t, v}
+		}
+		return u, nil
+	}
+	return 0, &TypeError{t, v}
+}
+
+// negative : gia tri la so am
+func negative(v interface{}) (int64, bool) {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(x.String(), 10, 64); err == nil {
+			return i, i < 0
+		}
+		if f, err := x.Float64(); err == nil {
+			return negative(f)
+		}
+	case float64:
+		if x < 0 && x == math.Trunc(x) && x >= math.MinInt64 {
+			return int64(x), true
+		}
+	case float32:
+		return negative(float64(x))
+	case int, int8, int16, int32, int64:
+		if i := cast.ToInt64(x); i < 0 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// toFloat : so thuc tu gia tri JSON (json.Number, float64) hoac gia tri Go
+func toFloat(v interface{}) (float64, error) {
+	if n, ok := v.(json.Number); ok {
+		return strconv.ParseFloat(n.String(), 64)
+	}
+	return cast.ToFloat64E(v)
+}
+
+func maxUnsigned(size int) uint64 {
+	if size >= 8 {
+		return math.MaxUint64
+	}
+	return 1<<(8*uint(size)) - 1
+}
+
+func maxSigned(size int) int64 {
+	return int64(maxUnsigned(size) >> 1)
+}
+
+func minSigned(size int) int64 {
+	return -maxSigned(size) - 1
+}
+
+// signExtend : so bu 2 do rong size byte -> int64
+func signExtend(u uint64, size int) int64 {
+	shift := 64 - 8*uint(size)
+	return int64(u<<shift) >> shift
+}
+
+func maxStringLength(t DataType) int {
+	if t == LongOctetString || t == LongCharString {
+		return maxLongString
+	}
+	return maxShortString
+}
@@ -0,0 +1,248 @@
+package zcl
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// errKind : loai loi de so sanh trong bang test, "" neu khong loi
+func errKind(err error) string {
+	switch err.(type) {
+	case nil:
+		return ""
+	case *RangeError:
+		return "range"
+	case *TypeError:
+		return "type"
+	}
+	return "other"
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     DataType
+		raw     interface{}
+		want    interface{}
+		wantErr string
+	}{
+		// so nguyen
+		{"uint8 max", Uint8, json.Number("255"), uint64(255), ""},
+		{"uint8 overflow", Uint8, json.Number("256"), nil, "range"},
+		{"uint8 negative", Uint8, json.Number("-1"), nil, "range"},
+		{"uint8 fraction", Uint8, json.Number("1.5"), nil, "range"},
+		{"uint16 float64", Uint16, float64(65535), uint64(65535), ""},
+		{"uint24 overflow", Uint24, float64(1 << 24), nil, "range"},
+		{"uint8 not a number", Uint8, "abc", nil, "type"},
+		{"int24 -1 two's complement", Int24, json.Number("16777215"), int64(-1), ""},
+		{"int24 min two's complement", Int24, json.Number("8388608"), int64(-1 << 23), ""},
+		{"int24 max", Int24, json.Number("8388607"), int64(1<<23 - 1), ""},
+		{"int24 negative", Int24, json.Number("-5"), int64(-5), ""},
+		{"int24 min", Int24, json.Number("-8388608"), int64(-1 << 23), ""},
+		{"int24 below min", Int24, json.Number("-8388609"), nil, "range"},
+		{"int24 overflow", Int24, json.Number("16777216"), nil, "range"},
+		{"int40 -1 two's complement", Int40, json.Number("1099511627775"), int64(-1), ""},
+		{"int40 min two's complement", Int40, float64(1 << 39), int64(-1 << 39), ""},
+		{"int40 max", Int40, json.Number("549755813887"), int64(1<<39 - 1), ""},
+		{"int40 below min", Int40, json.Number("-549755813889"), nil, "range"},
+		{"int40 overflow", Int40, json.Number("1099511627776"), nil, "range"},
+		{"bool", Bool, json.Number("1"), true, ""},
+		{"bool overflow", Bool, json.Number("2"), nil, "range"},
+
+		// so thuc
+		{"semi smallest subnormal", SemiFloat, json.Number("1"), float32(math.Ldexp(1, -24)), ""},
+		{"semi largest subnormal", SemiFloat, json.Number("1023"), float32(math.Ldexp(1023, -24)), ""},
+		{"semi max", SemiFloat, json.Number("31743"), float32(65504), ""},
+		{"semi +inf", SemiFloat, json.Number("31744"), float32(math.Inf(1)), ""},
+		{"semi -inf", SemiFloat, json.Number("64512"), float32(math.Inf(-1)), ""},
+		{"semi overflow", SemiFloat, json.Number("65536"), nil, "range"},
+		{"single", SingleFloat, json.Number("1.5"), float32(1.5), ""},
+		{"single overflow", SingleFloat, json.Number("1e39"), nil, "range"},
+		{"double", DoubleFloat, json.Number("1e300"), float64(1e300), ""},
+
+		// UTC time, giay tu 2000-01-01
+		{"utc epoch", UTCTime, json.Number("0"), time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC), ""},
+		{"utc 1 day", UTCTime, json.Number("86400"), time.Date(2000, time.January, 2, 0, 0, 0, 0, time.UTC), ""},
+		{"utc last valid", UTCTime, json.Number("4294967294"), Epoch.Add(4294967294 * time.Second), ""},
+		{"utc invalid", UTCTime, json.Number("4294967295"), nil, "range"},
+
+		// chuoi kem do dai
+		{"char string", CharString, "03616263", "abc", ""},
+		{"char string empty", CharString, "00", "", ""},
+		{"char string invalid length", CharString, "FF", "", ""},
+		{"char string truncated", CharString, "0561", nil, "other"},
+		{"char string trailing bytes", CharString, "016162", nil, "other"},
+		{"char string not hex", CharString, "abc", nil, "type"},
+		{"octet string", OctetString, "020102", []byte{1, 2}, ""},
+		{"octet string invalid length", OctetString, "FF", []byte{}, ""},
+		{"long char string", LongCharString, "02006162", "ab", ""},
+		{"long octet string 256 bytes", LongOctetString, "0001" + strings.Repeat("00", 256), make([]byte, 256), ""},
+		{"long octet string invalid length", LongOctetString, "FFFF", []byte{}, ""},
+		{"long octet string missing prefix byte", LongOctetString, "01", nil, "other"},
+
+		{"ieee", IEEEAddress, "00:12:4b:00:01:a2:b3:c4", IEEE(0x00124B0001A2B3C4), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.typ, tt.raw)
+			if errKind(err) != tt.wantErr {
+				t.Fatalf("Decode(%s, %v) error = %v, want %s error", tt.typ, tt.raw, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Decode(%s, %v) = %v (%T), want %v (%T)", tt.typ, tt.raw, got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     DataType
+		value   interface{}
+		want    interface{}
+		wantErr string
+	}{
+		// so nguyen
+		{"uint8", Uint8, uint8(200), uint64(200), ""},
+		{"uint8 overflow", Uint8, 256, nil, "range"},
+		{"uint16 negative", Uint16, int16(-1), nil, "range"},
+		{"uint16 negative float", Uint16, float64(-1), nil, "range"},
+		{"uint32 fraction", Uint32, float32(1.5), nil, "range"},
+		{"int24 min", Int24, int32(-1 << 23), int64(-1 << 23), ""},
+		{"int24 max", Int24, int32(1<<23 - 1), int64(1<<23 - 1), ""},
+		{"int24 below min", Int24, int32(-1<<23 - 1), nil, "range"},
+		{"int24 overflow", Int24, int32(1 << 23), nil, "range"},
+		{"int40 overflow", Int40, int64(1 << 39), nil, "range"},
+		{"int40 below min", Int40, int64(-1<<39 - 1), nil, "range"},
+		{"int64 from uint64 overflow", Int64, uint64(math.MaxInt64 + 1), nil, "range"},
+		{"int8 not a number", Int8, "abc", nil, "type"},
+
+		// so thuc
+		{"semi 1", SemiFloat, float32(1), uint16(0x3C00), ""},
+		{"semi max", SemiFloat, float64(65504), uint16(0x7BFF), ""},
+		{"semi overflow", SemiFloat, float64(65520), nil, "range"},
+		{"semi +inf", SemiFloat, math.Inf(1), uint16(0x7C00), ""},
+		{"semi -inf", SemiFloat, float32(math.Inf(-1)), uint16(0xFC00), ""},
+		{"semi nan", SemiFloat, math.NaN(), uint16(0x7E00), ""},
+		{"semi smallest subnormal", SemiFloat, math.Ldexp(1, -24), uint16(0x0001), ""},
+		{"semi half of smallest subnormal rounds to even", SemiFloat, math.Ldexp(1, -25), uint16(0x0000), ""},
+		{"semi underflow keeps sign", SemiFloat, -math.Ldexp(1, -30), uint16(0x8000), ""},
+		{"single overflow", SingleFloat, float64(1e39), nil, "range"},
+		{"double", DoubleFloat, float32(0.5), float64(0.5), ""},
+
+		// UTC time
+		{"utc epoch", UTCTime, "2000-01-01T00:00:00Z", uint64(0), ""},
+		{"utc rfc3339 with offset", UTCTime, "2000-01-01T07:01:00+07:00", uint64(60), ""},
+		{"utc unix seconds", UTCTime, int64(946684800 + 86400), uint64(86400), ""},
+		{"utc time.Time", UTCTime, time.Date(2000, time.January, 1, 1, 0, 0, 0, time.UTC), uint64(3600), ""},
+		{"utc before epoch", UTCTime, "1999-12-31T23:59:59Z", nil, "range"},
+		{"utc invalid", UTCTime, Epoch.Add(invalidUTC * time.Second), nil, "range"},
+		{"utc not a time", UTCTime, "yesterday", nil, "type"},
+
+		// chuoi kem do dai
+		{"char string", CharString, "abc", "03616263", ""},
+		{"char string empty", CharString, "", "00", ""},
+		{"char string max", CharString, strings.Repeat("a", 254), "fe" + strings.Repeat("61", 254), ""},
+		{"char string too long", CharString, strings.Repeat("a", 255), nil, "range"},
+		{"octet string from hex", OctetString, "0102", "020102", ""},
+		{"octet string from bytes", OctetString, []byte{0xAB}, "01ab", ""},
+		{"octet string not hex", OctetString, "xyz", nil, "type"},
+		{"long char string", LongCharString, strings.Repeat("a", 255), "ff00" + strings.Repeat("61", 255), ""},
+		{"long octet string", LongOctetString, []byte{1, 2}, "02000102", ""},
+
+		{"ieee", IEEEAddress, "00-12-4B-00-01-A2-B3-C4", "00124B0001A2B3C4", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Encode(tt.typ, tt.value)
+			if errKind(err) != tt.wantErr {
+				t.Fatalf("Encode(%s, %v) error = %v, want %s error", tt.typ, tt.value, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Encode(%s, %v) = %v (%T), want %v (%T)", tt.typ, tt.value, got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+// TestHalfRoundTrip : moi gia tri half (tru NaN) -> float32 -> half giu nguyen bit
+func TestHalfRoundTrip(t *testing.T) {
+	for h := 0; h <= math.MaxUint16; h++ {
+		f := halfToFloat32(uint16(h))
+		if h&0x7C00 == 0x7C00 && h&0x3FF != 0 {
+			if !math.IsNaN(float64(f)) {
+				t.Fatalf("halfToFloat32(%#04x) = %v, want NaN", h, f)
+			}
+			continue
+		}
+		if got := float32ToHalf(f); got != uint16(h) {
+			t.Fatalf("float32ToHalf(halfToFloat32(%#04x) = %v) = %#04x", h, f, got)
+		}
+	}
+	if f := halfToFloat32(0x8000); f != 0 || !math.Signbit(float64(f)) {
+		t.Fatalf("halfToFloat32(0x8000) = %v, want -0", f)
+	}
+}
+
+// TestLegacyString : chuoi khong co do dai cua firmware cu (StringFormat = legacy)
+func TestLegacyString(t *testing.T) {
+	decodeTests := []struct {
+		name    string
+		typ     DataType
+		raw     interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{"char string", CharString, "abc", "abc", ""},
+		{"char string empty", CharString, "", "", ""},
+		{"char string max", CharString, strings.Repeat("a", 254), strings.Repeat("a", 254), ""},
+		{"char string too long", CharString, strings.Repeat("a", 255), nil, "range"},
+		{"long char string", LongCharString, strings.Repeat("a", 255), strings.Repeat("a", 255), ""},
+		{"octet string", OctetString, "0102", []byte{1, 2}, ""},
+		{"octet string too long", OctetString, strings.Repeat("00", 255), nil, "range"},
+		{"octet string not hex", OctetString, "xyz", nil, "type"},
+		{"not a string", CharString, json.Number("1"), nil, "type"},
+		{"not a string type", Uint8, "01", nil, "other"},
+	}
+	for _, tt := range decodeTests {
+		t.Run("decode "+tt.name, func(t *testing.T) {
+			got, err := DecodeLegacyString(tt.typ, tt.raw)
+			if errKind(err) != tt.wantErr {
+				t.Fatalf("DecodeLegacyString(%s, %v) error = %v, want %s error", tt.typ, tt.raw, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DecodeLegacyString(%s, %v) = %#v, want %#v", tt.typ, tt.raw, got, tt.want)
+			}
+		})
+	}
+
+	encodeTests := []struct {
+		name    string
+		typ     DataType
+		value   interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{"char string", CharString, "abc", "abc", ""},
+		{"char string too long", CharString, strings.Repeat("a", 255), nil, "range"},
+		{"octet string from hex", OctetString, "0102", "0102", ""},
+		{"octet string from bytes", LongOctetString, []byte{0xAB, 0xCD}, "abcd", ""},
+		{"octet string not hex", OctetString, "xyz", nil, "type"},
+	}
+	for _, tt := range encodeTests {
+		t.Run("encode "+tt.name, func(t *testing.T) {
+			got, err := EncodeLegacyString(tt.typ, tt.value)
+			if errKind(err) != tt.wantErr {
+				t.Fatalf("EncodeLegacyString(%s, %v) error = %v, want %s error", tt.typ, tt.value, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("EncodeLegacyString(%s, %v) = %#v, want %#v", tt.typ, tt.value, got, tt.want)
+			}
+		})
+	}
+}
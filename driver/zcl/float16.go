@@ -0,0 +1,71 @@
+package zcl
+
+import "math"
+
+// halfToFloat32 : IEEE 754 half precision (semi float) -> float32
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1F
+	frac := uint32(h) & 0x3FF
+
+	switch {
+	case exp == 0 && frac == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// so khong chuan hoa: chuan hoa lai cho float32
+		e := uint32(127 - 15 + 1)
+		for frac&0x400 == 0 {
+			frac <<= 1
+			e--
+		}
+		frac &= 0x3FF
+		return math.Float32frombits(sign | e<<23 | frac<<13)
+	case exp == 0x1F:
+		// Inf, NaN
+		return math.Float32frombits(sign | 0xFF<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
+
+// float32ToHalf : float32 -> half precision, lam tron ve so gan nhat (chan)
+func float32ToHalf(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int32(b>>23) & 0xFF
+	frac := b & 0x7FFFFF
+
+	if exp == 0xFF {
+		if frac != 0 {
+			return sign | 0x7E00 // NaN
+		}
+		return sign | 0x7C00 // Inf
+	}
+
+	e := exp - 127 + 15
+	if e >= 0x1F {
+		return sign | 0x7C00
+	}
+	if e <= 0 {
+		if e < -10 {
+			return sign
+		}
+		// so khong chuan hoa
+		frac |= 0x800000
+		shift := uint32(14 - e)
+		h := frac >> shift
+		rem := frac & (1<<shift - 1)
+		half := uint32(1) << (shift - 1)
+		if rem > half || (rem == half && h&1 == 1) {
+			h++
+		}
+		return sign | uint16(h)
+	}
+
+	h := uint32(e)<<10 | frac>>13
+	rem := frac & 0x1FFF
+	if rem > 0x1000 || (rem == 0x1000 && h&1 == 1) {
+		// lam tron co the tran sang exponent, van dung (toi da thanh Inf)
+		h++
+	}
+	return sign | uint16(h)
+}
@@ -0,0 +1,144 @@
+package zcl
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// Chuoi ZCL (octet string, char string) co do dai o dau: 1 byte voi kieu ngan,
+// 2 byte little endian voi kieu long. Do dai 0xFF (0xFFFF) danh dau gia tri khong hop le
+
+// prefixSize : so byte do dai cua kieu chuoi
+func prefixSize(t DataType) int {
+	if t == LongOctetString || t == LongCharString {
+		return 2
+	}
+	return 1
+}
+
+// AppendString : them chuoi s kem do dai theo kieu t vao b
+func AppendString(b []byte, t DataType, s []byte) ([]byte, error) {
+	if k := t.Kind(); k != KindOctets && k != KindString {
+		return b, fmt.Errorf("zcl: %s is not a string type", t)
+	}
+	if len(s) > maxStringLength(t) {
+		return b, &RangeError{t, len(s)}
+	}
+	if prefixSize(t) == 2 {
+		b = append(b, byte(len(s)), byte(len(s)>>8))
+	} else {
+		b = append(b, byte(len(s)))
+	}
+	return append(b, s...), nil
+}
+
+// ParseString : tach chuoi kieu t o dau b, tra ve noi dung va so byte da doc.
+// Gia tri khong hop le (0xFF, 0xFFFF) tra ve noi dung rong
+func ParseString(t DataType, b []byte) ([]byte, int, error) {
+	if k := t.Kind(); k != KindOctets && k != KindString {
+		return nil, 0, fmt.Errorf("zcl: %s is not a string type", t)
+	}
+	size := prefixSize(t)
+	if len(b) < size {
+		return nil, 0, fmt.Errorf("zcl: %s truncated: %d bytes", t, len(b))
+	}
+	var n int
+	if size == 2 {
+		n = int(binary.LittleEndian.Uint16(b))
+	} else {
+		n = int(b[0])
+	}
+	if n > maxStringLength(t) {
+		return []byte{}, size, nil
+	}
+	if len(b) < size+n {
+		return nil, 0, fmt.Errorf("zcl: %s truncated: length %d, %d bytes", t, n, len(b)-size)
+	}
+	return b[size : size+n], size + n, nil
+}
+
+// EncodeString : gia tri Go -> chuoi kieu t kem do dai.
+// Octet string nhan []byte hoac chuoi hex, char string nhan chuoi hoac []byte
+func EncodeString(t DataType, v interface{}) ([]byte, error) {
+	var s []byte
+	switch x := v.(type) {
+	case []byte:
+		s = x
+	case string:
+		if t.Kind() == KindOctets {
+			var err error
+			s, err = hex.DecodeString(x)
+			if err != nil {
+				return nil, &TypeError{t, v}
+			}
+		} else {
+			s = []byte(x)
+		}
+	default:
+		if t.Kind() != KindString {
+			return nil, &TypeError{t, v}
+		}
+		s = []byte(fmt.Sprint(v))
+	}
+	return AppendString(nil, t, s)
+}
+
+// decodeString : gia tri "val" cua chuoi tren frame (hex cua do dai + noi dung) -> noi dung
+func decodeString(t DataType, raw interface{}) ([]byte, error) {
+	s, ok := raw.(string)
+	if !ok {
+		return nil, &TypeError{t, raw}
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, &TypeError{t, raw}
+	}
+	v, n, err := ParseString(t, b)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(b) {
+		return nil, fmt.Errorf("zcl: %s has %d trailing bytes", t, len(b)-n)
+	}
+	return v, nil
+}
+
+// DecodeLegacyString : gia tri "val" cua chuoi tu firmware cu, khong co do dai:
+// octet string la hex cua noi dung, char string la chuoi thuong
+func DecodeLegacyString(t DataType, raw interface{}) (interface{}, error) {
+	s, ok := raw.(string)
+	if !ok {
+		return nil, &TypeError{t, raw}
+	}
+	switch t.Kind() {
+	case KindOctets:
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, &TypeError{t, raw}
+		}
+		if len(b) > maxStringLength(t) {
+			return nil, &RangeError{t, len(b)}
+		}
+		return b, nil
+	case KindString:
+		if len(s) > maxStringLength(t) {
+			return nil, &RangeError{t, len(s)}
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("zcl: %s is not a string type", t)
+}
+
+// EncodeLegacyString : gia tri Go -> "val" cua chuoi cho firmware cu, khong co do dai
+func EncodeLegacyString(t DataType, v interface{}) (interface{}, error) {
+	b, err := EncodeString(t, v)
+	if err != nil {
+		return nil, err
+	}
+	content := b[prefixSize(t):]
+	if t.Kind() == KindOctets {
+		return hex.EncodeToString(content), nil
+	}
+	return string(content), nil
+}
@@ -0,0 +1,181 @@
+// Package zcl : cac kieu du lieu cua Zigbee Cluster Library (ZCL spec, Table 2-10)
+// va chuyen doi giua gia tri tren frame (JSON) va gia tri Go.
+//
+// Gia tri "val" tren frame la gia tri tho cua thuoc tinh:
+//
+//	bool                    true/false hoac 0/1
+//	bitmap/uint/enum        so khong dau
+//	int8-64                 so co dau, hoac dang bu 2 khong dau theo do rong (0xFFFFFF = -1 voi int24)
+//	semi float              16 bit IEEE 754 half precision dang so khong dau
+//	single/double float     so thuc
+//	octet/char string       chuoi hex gom do dai (1 byte, 2 byte little endian voi kieu long) va noi dung
+//	UTC time                so giay tu 2000-01-01 00:00:00 UTC
+//	IEEE address            chuoi hex 16 ky tu hoac so
+//
+// Firmware cu gui chuoi khong co do dai, xem DecodeLegacyString va EncodeLegacyString.
+package zcl
+
+import "fmt"
+
+// DataType : ma kieu du lieu ZCL, gia tri cua AttributeInfo.ValueType
+type DataType uint8
+
+const (
+	NoData DataType = 0x00
+
+	Data8  DataType = 0x08
+	Data16 DataType = 0x09
+	Data24 DataType = 0x0A
+	Data32 DataType = 0x0B
+	Data40 DataType = 0x0C
+	Data48 DataType = 0x0D
+	Data56 DataType = 0x0E
+	Data64 DataType = 0x0F
+
+	Bool DataType = 0x10
+
+	Bitmap8  DataType = 0x18
+	Bitmap16 DataType = 0x19
+	Bitmap24 DataType = 0x1A
+	Bitmap32 DataType = 0x1B
+	Bitmap40 DataType = 0x1C
+	Bitmap48 DataType = 0x1D
+	Bitmap56 DataType = 0x1E
+	Bitmap64 DataType = 0x1F
+
+	Uint8  DataType = 0x20
+	Uint16 DataType = 0x21
+	Uint24 DataType = 0x22
+	Uint32 DataType = 0x23
+	Uint40 DataType = 0x24
+	Uint48 DataType = 0x25
+	Uint56 DataType = 0x26
+	Uint64 DataType = 0x27
+
+	Int8  DataType = 0x28
+	Int16 DataType = 0x29
+	Int24 DataType = 0x2A
+	Int32 DataType = 0x2B
+	Int40 DataType = 0x2C
+	Int48 DataType = 0x2D
+	Int56 DataType = 0x2E
+	Int64 DataType = 0x2F
+
+	Enum8  DataType = 0x30
+	Enum16 DataType = 0x31
+
+	SemiFloat   DataType = 0x38
+	SingleFloat DataType = 0x39
+	DoubleFloat DataType = 0x3A
+
+	OctetString     DataType = 0x41
+	CharString      DataType = 0x42
+	LongOctetString DataType = 0x43
+	LongCharString  DataType = 0x44
+
+	UTCTime DataType = 0xE2
+
+	IEEEAddress DataType = 0xF0
+)
+
+// Kind : nhom kieu, quyet dinh cach chuyen doi
+type Kind uint8
+
+const (
+	KindUnknown Kind = iota
+	KindBool
+	KindUnsigned // data, bitmap, uint, enum
+	KindSigned
+	KindFloat
+	KindOctets
+	KindString
+	KindTime
+	KindIEEE
+)
+
+type typeInfo struct {
+	name string
+	kind Kind
+	size int // byte, 0 = do dai thay doi
+}
+
+var types = map[DataType]typeInfo{
+	NoData: {"nodata", KindUnknown, 0},
+
+	Data8:  {"data8", KindUnsigned, 1},
+	Data16: {"data16", KindUnsigned, 2},
+	Data24: {"data24", KindUnsigned, 3},
+	Data32: {"data32", KindUnsigned, 4},
+	Data40: {"data40", KindUnsigned, 5},
+	Data48: {"data48", KindUnsigned, 6},
+	Data56: {"data56", KindUnsigned, 7},
+	Data64: {"data64", KindUnsigned, 8},
+
+	Bool: {"bool", KindBool, 1},
+
+	Bitmap8:  {"bitmap8", KindUnsigned, 1},
+	Bitmap16: {"bitmap16", KindUnsigned, 2},
+	Bitmap24: {"bitmap24", KindUnsigned, 3},
+	Bitmap32: {"bitmap32", KindUnsigned, 4},
+	Bitmap40: {"bitmap40", KindUnsigned, 5},
+	Bitmap48: {"bitmap48", KindUnsigned, 6},
+	Bitmap56: {"bitmap56", KindUnsigned, 7},
+	Bitmap64: {"bitmap64", KindUnsigned, 8},
+
+	Uint8:  {"uint8", KindUnsigned, 1},
+	Uint16: {"uint16", KindUnsigned, 2},
+	Uint24: {"uint24", KindUnsigned, 3},
+	Uint32: {"uint32", KindUnsigned, 4},
+	Uint40: {"uint40", KindUnsigned, 5},
+	Uint48: {"uint48", KindUnsigned, 6},
+	Uint56: {"uint56", KindUnsigned, 7},
+	Uint64: {"uint64", KindUnsigned, 8},
+
+	Int8:  {"int8", KindSigned, 1},
+	Int16: {"int16", KindSigned, 2},
+	Int24: {"int24", KindSigned, 3},
+	Int32: {"int32", KindSigned, 4},
+	Int40: {"int40", KindSigned, 5},
+	Int48: {"int48", KindSigned, 6},
+	Int56: {"int56", KindSigned, 7},
+	Int64: {"int64", KindSigned, 8},
+
+	Enum8:  {"enum8", KindUnsigned, 1},
+	Enum16: {"enum16", KindUnsigned, 2},
+
+	SemiFloat:   {"semi", KindFloat, 2},
+	SingleFloat: {"single", KindFloat, 4},
+	DoubleFloat: {"double", KindFloat, 8},
+
+	OctetString:     {"octstr", KindOctets, 0},
+	CharString:      {"string", KindString, 0},
+	LongOctetString: {"octstr16", KindOctets, 0},
+	LongCharString:  {"string16", KindString, 0},
+
+	UTCTime: {"UTC", KindTime, 4},
+
+	IEEEAddress: {"EUI64", KindIEEE, 8},
+}
+
+// Known : t la kieu ZCL duoc codec ho tro. Profile cu dung ma rieng (vi du 1) se khong duoc chuyen doi
+func (t DataType) Known() bool {
+	info, ok := types[t]
+	return ok && info.kind != KindUnknown
+}
+
+// Kind : nhom cua kieu
+func (t DataType) Kind() Kind {
+	return types[t].kind
+}
+
+// Size : so byte cua gia tri, 0 voi chuoi
+func (t DataType) Size() int {
+	return types[t].size
+}
+
+func (t DataType) String() string {
+	if info, ok := types[t]; ok {
+		return info.name
+	}
+	return fmt.Sprintf("0x%02X", uint8(t))
+}
@@ -0,0 +1,129 @@
+package driver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/device-zigbee/driver/zcl"
+	sdkModel "github.com/edgexfoundry/device-sdk-go/pkg/models"
+)
+
+// ten khoa cau hinh dang chuoi ZCL tren frame trong muc [Driver]
+const nameStringFormatConfig = "StringFormat"
+
+// dang gia tri "val" cua octet/char string tren frame
+const (
+	stringFormatZCL    = "zcl"    // hex cua do dai + noi dung
+	stringFormatLegacy = "legacy" // firmware cu: hex cua noi dung (octet), chuoi thuong (char)
+)
+
+var (
+	stringFormatMutex sync.Mutex
+	stringFormat      = stringFormatZCL
+)
+
+// initStringFormat : doc dang chuoi ZCL tu muc [Driver] cua configuration.toml
+func initStringFormat(config map[string]string) error {
+	format := stringFormatZCL
+	if v, ok := configValue(config, nameStringFormatConfig); ok {
+		if v != stringFormatZCL && v != stringFormatLegacy {
+			return fmt.Errorf("Cau hinh %s khong hop le: %s", nameStringFormatConfig, v)
+		}
+		format = v
+	}
+	stringFormatMutex.Lock()
+	stringFormat = format
+	stringFormatMutex.Unlock()
+	return nil
+}
+
+// legacyStrings : true neu firmware gui/nhan chuoi ZCL khong co do dai
+func legacyStrings(t zcl.DataType) bool {
+	if k := t.Kind(); k != zcl.KindOctets && k != zcl.KindString {
+		return false
+	}
+	stringFormatMutex.Lock()
+	defer stringFormatMutex.Unlock()
+	return stringFormat == stringFormatLegacy
+}
+
+// readingOf : gia tri tho cua thuoc tinh -> gia tri cho newResult, theo ValueType cua thuoc tinh.
+// ValueType khong phai ma kieu ZCL (profile cu, vi du "1") thi giu nguyen gia tri tho
+func readingOf(req sdkModel.CommandRequest, att AttributeInfo, raw interface{}) (interface{}, error) {
+	t := zcl.DataType(att.ValueType)
+	if !t.Known() {
+		if n, ok := raw.(json.Number); ok {
+			// nhu truoc khi frame duoc doc voi UseNumber
+			return n.Float64()
+		}
+		return raw, nil
+	}
+	decode := zcl.Decode
+	if legacyStrings(t) {
+		decode = zcl.DecodeLegacyString
+	}
+	v, err := decode(t, raw)
+	if err != nil {
+		return nil, err
+	}
+	switch x := v.(type) {
+	case time.Time:
+		// resource String: RFC3339, resource so: Unix time (s)
+		if req.Type == sdkModel.String {
+			return x.Format(time.RFC3339), nil
+		}
+		return x.Unix(), nil
+	case []byte:
+		// resource Binary: noi dung, resource String: chuoi hex
+		if req.Type == sdkModel.Binary {
+			return x, nil
+		}
+		return hex.EncodeToString(x), nil
+	case zcl.IEEE:
+		if req.Type == sdkModel.String {
+			return x.String(), nil
+		}
+		return uint64(x), nil
+	}
+	return v, nil
+}
+
+// attributeValueOf : gia tri cua CommandValue -> gia tri tho gui toi thiet bi, kiem tra mien gia tri
+func attributeValueOf(att AttributeInfo, v interface{}) (interface{}, error) {
+	t := zcl.DataType(att.ValueType)
+	if !t.Known() {
+		return v, nil
+	}
+	if legacyStrings(t) {
+		return zcl.EncodeLegacyString(t, v)
+	}
+	return zcl.Encode(t, v)
+}
+
+// attributeBytes : gia tri cua thuoc tinh trong frame nhi phan (Subscribe, Schedule).
+// Chuoi ZCL kem byte do dai (tru StringFormat legacy), kieu khac nhu getBytes
+func attributeBytes(att AttributeInfo, v interface{}) ([]byte, error) {
+	t := zcl.DataType(att.ValueType)
+	if k := t.Kind(); (k == zcl.KindOctets || k == zcl.KindString) && !legacyStrings(t) {
+		return zcl.EncodeString(t, v)
+	}
+	return getBytes(v)
+}
+
+// toBinary : gia tri cho resource Binary, chuoi duoc coi la hex (profile cu khong co ma kieu ZCL)
+func toBinary(reading interface{}) ([]byte, error) {
+	switch v := reading.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		b, err := hex.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to cast %q to []byte: %v", v, err)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unable to cast %#v of type %T to []byte", reading, reading)
+}
@@ -0,0 +1,102 @@
+package driver
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/device-zigbee/driver/zcl"
+	sdkModel "github.com/edgexfoundry/device-sdk-go/pkg/models"
+)
+
+// TestValueFrameRoundTrip : gia tri ghi -> frame JSON -> frame nhan -> gia tri doc,
+// so nguyen lon hon 2^53 khong duoc qua float64
+func TestValueFrameRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   zcl.DataType
+		value interface{}
+	}{
+		{"uint64 2^53+1", zcl.Uint64, uint64(1<<53 + 1)},
+		{"uint64 max", zcl.Uint64, uint64(math.MaxUint64)},
+		{"uint40 max", zcl.Uint40, uint64(1<<40 - 1)},
+		{"int48 min", zcl.Int48, int64(-1 << 47)},
+		{"int64 -(2^53+1)", zcl.Int64, int64(-(1<<53 + 1))},
+		{"int64 max", zcl.Int64, int64(math.MaxInt64)},
+		{"double 2^60+2^8", zcl.DoubleFloat, float64(1<<60 + 1<<8)},
+		{"double max", zcl.DoubleFloat, math.MaxFloat64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			att := AttributeInfo{ProfileID: 260, ClusterID: 0x0702, AttributeID: 0, ValueType: uint8(tt.typ)}
+			raw, err := attributeValueOf(att, tt.value)
+			if err != nil {
+				t.Fatalf("attributeValueOf: %v", err)
+			}
+			payload, err := json.Marshal(CommandFrame{
+				ObjectAddress: ObjectAddress{Address: 1, Type: 1, Endpoint: 1},
+				AttributeInfo: att,
+				Value:         raw,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := decodeResponseFrame(payload)
+			if err != nil {
+				t.Fatalf("decodeResponseFrame: %v", err)
+			}
+			got, err := readingOf(sdkModel.CommandRequest{}, att, response.Value)
+			if err != nil {
+				t.Fatalf("readingOf(%v): %v", response.Value, err)
+			}
+			if got != tt.value {
+				t.Fatalf("reading = %v (%T), want %v (%T)", got, got, tt.value, tt.value)
+			}
+		})
+	}
+}
+
+func TestReadingOfUnknownType(t *testing.T) {
+	response, err := decodeResponseFrame([]byte(`{"addr":1,"vltp":1,"val":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// profile cu: ValueType khong phai ma ZCL, gia tri van la float64 nhu truoc
+	got, err := readingOf(sdkModel.CommandRequest{}, response.AttributeInfo, response.Value)
+	if err != nil || got != float64(1) {
+		t.Fatalf("readingOf = %v (%T), %v", got, got, err)
+	}
+}
+
+// TestStringFormat : StringFormat = legacy doc/ghi chuoi khong co byte do dai
+func TestStringFormat(t *testing.T) {
+	defer initStringFormat(nil)
+	att := AttributeInfo{ProfileID: 260, ClusterID: 0, AttributeID: 5, ValueType: uint8(zcl.CharString)}
+	req := sdkModel.CommandRequest{Type: sdkModel.String}
+	tests := []struct {
+		format string
+		raw    string
+	}{
+		{stringFormatZCL, "03616263"},
+		{stringFormatLegacy, "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			err := initStringFormat(map[string]string{nameStringFormatConfig: tt.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := readingOf(req, att, tt.raw)
+			if err != nil || got != "abc" {
+				t.Fatalf("readingOf(%q) = %v, %v", tt.raw, got, err)
+			}
+			raw, err := attributeValueOf(att, "abc")
+			if err != nil || raw != tt.raw {
+				t.Fatalf("attributeValueOf = %v, %v, want %q", raw, err, tt.raw)
+			}
+		})
+	}
+	if err := initStringFormat(map[string]string{nameStringFormatConfig: "utf8"}); err == nil {
+		t.Fatal("initStringFormat accepted an unknown format")
+	}
+}
@@ -5,6 +5,8 @@ go 1.12
 require (
 	github.com/edgexfoundry/device-sdk-go v1.1.0
 	github.com/edgexfoundry/go-mod-core-contracts v0.1.31
+	github.com/pelletier/go-toml v1.2.0
 	github.com/spf13/cast v1.3.0
 	github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
+	golang.org/x/sys v0.0.0-20181026203630-95b1ffbd15a5
 )
@@ -0,0 +1,26 @@
+package simulator
+
+// cmd giong driver/utils.go
+const (
+	commandCmd int8 = iota
+	pushEventCmd
+	addObjectCmd
+	deleteObjectCmd
+	scanCmd
+	deviceAnnounceCmd
+)
+
+const (
+	commandIDRead   = 0x01
+	commandIDWrite  = 0x02
+	commandIDDelete = 0x03
+)
+
+// status ZCL tra ve trong truong "resp"
+const (
+	statusSuccess              = 0x00
+	statusFailure              = 0x01
+	statusNotFound             = 0x8B
+	statusUnsupportedAttribute = 0x86
+	statusReadOnly             = 0x88
+)
@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// PTY : cap pseudo-terminal, driver mo SlaveName nhu mot cong serial
+type PTY struct {
+	*os.File  // dau master, simulator doc/ghi
+	slave     *os.File
+	SlaveName string
+}
+
+// OpenPTY : tao pseudo-terminal o che do raw.
+// Dau slave duoc giu mo de dau master khong tra ve EIO khi driver dong/mo lai cong.
+func OpenPTY() (*PTY, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	fd := master.Fd()
+
+	unlock := 0
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, unix.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock)))
+	if errno != 0 {
+		master.Close()
+		return nil, fmt.Errorf("unlockpt: %v", errno)
+	}
+	n, err := unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("ptsname: %v", err)
+	}
+	name := fmt.Sprintf("/dev/pts/%d", n)
+
+	slave, err := os.OpenFile(name, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	err = makeRaw(int(slave.Fd()))
+	if err != nil {
+		slave.Close()
+		master.Close()
+		return nil, err
+	}
+	return &PTY{File: master, slave: slave, SlaveName: name}, nil
+}
+
+// Close : dong ca hai dau
+func (p *PTY) Close() error {
+	p.slave.Close()
+	return p.File.Close()
+}
+
+// makeRaw : tuong duong cfmakeraw
+func makeRaw(fd int) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB
+	t.Cflag |= unix.CS8
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.TCSETS, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
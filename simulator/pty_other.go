@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package simulator
+
+import (
+	"fmt"
+	"os"
+)
+
+// PTY : cap pseudo-terminal, driver mo SlaveName nhu mot cong serial
+type PTY struct {
+	*os.File
+	SlaveName string
+}
+
+// OpenPTY : chi ho tro tren linux
+func OpenPTY() (*PTY, error) {
+	return nil, fmt.Errorf("pseudo-terminal chi ho tro tren linux")
+}
@@ -0,0 +1,522 @@
+// Package simulator : coordinator Zigbee ao de chay driver khong can phan cung.
+// Simulator noi chuyen voi driver bang dung dinh dang frame 0x55|len|cmd|JSON|CRC,
+// giu bang thiet bi ao, tra loi lenh doc/ghi, them/xoa doi tuong, scan va
+// dinh ky gui PushEvent cho cac attribute co Report.
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/device-zigbee/driver/frame"
+)
+
+// loai doi tuong trong truong "type", giong property Type cua driver
+const (
+	TypeDevice   = 'D'
+	TypeGroup    = 'G'
+	TypeScenario = 'S'
+)
+
+// groupMAC : MAC driver gui khi them group/scenario
+const groupMAC = "00000000"
+
+// Attribute : attribute ZCL cua thiet bi ao
+type Attribute struct {
+	ProfileID   uint16      `json:"pro"`
+	ClusterID   uint16      `json:"clu"`
+	AttributeID uint16      `json:"att"`
+	ValueType   uint8       `json:"vltp"`
+	Value       interface{} `json:"val"`
+	ReadOnly    bool        `json:"readOnly,omitempty"`
+	Report      bool        `json:"report,omitempty"` // gui PushEvent dinh ky va khi gia tri thay doi
+}
+
+// Device : thiet bi ao trong vung phu song cua coordinator
+type Device struct {
+	MAC         string      `json:"MAC"`
+	PAN         uint16      `json:"PAN,omitempty"`
+	Endpoint    uint8       `json:"endp,omitempty"`
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"desc,omitempty"`
+	Attributes  []Attribute `json:"attributes,omitempty"`
+	Offline     bool        `json:"offline,omitempty"` // khong tra loi lenh gui toi thiet bi
+	Joined      bool        `json:"joined,omitempty"`  // da tham gia mang, chua joined thi cho scan
+	Address     uint16      `json:"addr,omitempty"`    // dia chi mang, cap khi tham gia mang
+}
+
+// Config : cau hinh simulator
+type Config struct {
+	Devices        []Device
+	PAN            uint16
+	ReportInterval time.Duration // 0 = khong gui PushEvent dinh ky
+	ResponseDelay  time.Duration // do tre truoc khi tra loi
+	Logf           func(format string, args ...interface{})
+}
+
+// message : payload JSON cua moi frame, cac truong theo driver/utils.go
+type message struct {
+	MAC         string      `json:"MAC,omitempty"`
+	PAN         uint16      `json:"PAN,omitempty"`
+	Address     uint16      `json:"addr"`
+	Type        uint8       `json:"type"`
+	Endpoint    uint8       `json:"endp"`
+	Seq         uint8       `json:"seq,omitempty"`
+	Status      uint8       `json:"resp"`
+	CommandID   int8        `json:"cmid,omitempty"`
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"desc,omitempty"`
+	ScanTime    int8        `json:"scan,omitempty"`
+	Leave       bool        `json:"leave,omitempty"`
+	ProfileID   uint16      `json:"pro"`
+	ClusterID   uint16      `json:"clu"`
+	AttributeID uint16      `json:"att"`
+	ValueType   uint8       `json:"vltp"`
+	Value       interface{} `json:"val,omitempty"`
+}
+
+// object : doi tuong da co dia chi mang
+type object struct {
+	typ      uint8
+	mac      string // rong voi group/scenario
+	name     string
+	endpoint uint8
+}
+
+// Simulator : coordinator ao
+type Simulator struct {
+	cfg Config
+
+	mutex    sync.Mutex
+	devices  map[string]*Device // theo MAC
+	objects  map[uint16]*object // theo dia chi mang
+	nextAddr uint16
+
+	wmutex sync.Mutex
+	w      io.Writer
+}
+
+// New : tao simulator voi bang thiet bi ban dau
+func New(cfg Config) *Simulator {
+	if cfg.Logf == nil {
+		cfg.Logf = func(string, ...interface{}) {}
+	}
+	s := &Simulator{
+		cfg:      cfg,
+		devices:  make(map[string]*Device),
+		objects:  make(map[uint16]*object),
+		nextAddr: 1,
+	}
+	for _, dev := range cfg.Devices {
+		s.AddDevice(dev)
+	}
+	return s
+}
+
+// AddDevice : them thiet bi ao, thiet bi Joined duoc cap dia chi ngay
+func (s *Simulator) AddDevice(dev Device) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	d := dev
+	if d.PAN == 0 {
+		d.PAN = s.cfg.PAN
+	}
+	if d.Endpoint == 0 {
+		d.Endpoint = 1
+	}
+	d.Attributes = append([]Attribute(nil), dev.Attributes...)
+	s.devices[d.MAC] = &d
+	if d.Joined {
+		s.joinWithoutSync(&d)
+	}
+}
+
+// Devices : ban sao bang thiet bi ao
+func (s *Simulator) Devices() []Device {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		c := *d
+		c.Attributes = append([]Attribute(nil), d.Attributes...)
+		result = append(result, c)
+	}
+	return result
+}
+
+// SetAttribute : doi gia tri attribute nhu khi thiet bi thay doi tai cho, gui PushEvent neu co Report
+func (s *Simulator) SetAttribute(mac string, clusterID uint16, attributeID uint16, value interface{}) error {
+	s.mutex.Lock()
+	d, ok := s.devices[mac]
+	if !ok {
+		s.mutex.Unlock()
+		return fmt.Errorf("khong co thiet bi %s", mac)
+	}
+	att := findAttribute(d, clusterID, attributeID)
+	if att == nil {
+		s.mutex.Unlock()
+		return fmt.Errorf("thiet bi %s khong co attribute %d/%d", mac, clusterID, attributeID)
+	}
+	att.Value = value
+	report := att.Report && d.Joined
+	var msg message
+	if report {
+		msg = pushMessage(d, *att)
+	}
+	s.mutex.Unlock()
+
+	if report {
+		return s.send(pushEventCmd, msg)
+	}
+	return nil
+}
+
+// Rejoin : thiet bi tham gia lai mang voi dia chi ngan moi va gui device-announce
+func (s *Simulator) Rejoin(mac string) error {
+	s.mutex.Lock()
+	d, ok := s.devices[mac]
+	if !ok || !d.Joined {
+		s.mutex.Unlock()
+		return fmt.Errorf("thiet bi %s chua tham gia mang", mac)
+	}
+	old := d.Address
+	d.Address = s.allocAddressWithoutSync()
+	s.objects[d.Address] = s.objects[old]
+	delete(s.objects, old)
+	msg := deviceMessage(d)
+	s.mutex.Unlock()
+
+	s.cfg.Logf("simulator: %s tham gia lai mang, Address %d -> %d", mac, old, msg.Address)
+	return s.send(deviceAnnounceCmd, msg)
+}
+
+// Serve : xu ly frame tu driver cho den khi rw bi dong hoac ctx ket thuc
+func (s *Simulator) Serve(ctx context.Context, rw io.ReadWriter) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.wmutex.Lock()
+	s.w = rw
+	s.wmutex.Unlock()
+	defer func() {
+		s.wmutex.Lock()
+		s.w = nil
+		s.wmutex.Unlock()
+	}()
+
+	if c, ok := rw.(io.Closer); ok {
+		go func() {
+			<-ctx.Done()
+			c.Close()
+		}()
+	}
+	if s.cfg.ReportInterval > 0 {
+		go s.reportLoop(ctx)
+	}
+
+	decoder := frame.NewDecoder(rw, 0)
+	for {
+		rx, err := decoder.Decode()
+		if err != nil {
+			if frame.IsFrameError(err) {
+				s.cfg.Logf("simulator: bo frame loi: %v", err)
+				continue
+			}
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		cmd := int8(rx.Cmd)
+		var req message
+		// giu "val" dang json.Number de ghi/doc lai dung so nguyen 64 bit
+		d := json.NewDecoder(bytes.NewReader(rx.Payload))
+		d.UseNumber()
+		err = d.Decode(&req)
+		if err != nil {
+			s.cfg.Logf("simulator: bo qua payload khong hop le: %v", err)
+			continue
+		}
+		go s.handle(ctx, cmd, req)
+	}
+}
+
+func (s *Simulator) handle(ctx context.Context, cmd int8, req message) {
+	if s.cfg.ResponseDelay > 0 {
+		select {
+		case <-time.After(s.cfg.ResponseDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	var resp *message
+	var announce []message
+	switch cmd {
+	case commandCmd:
+		resp = s.handleCommand(req)
+	case addObjectCmd:
+		resp = s.handleAddObject(req)
+	case deleteObjectCmd:
+		resp = s.handleDeleteObject(req)
+	case scanCmd:
+		resp, announce = s.handleScan(req)
+	default:
+		s.cfg.Logf("simulator: khong ho tro cmd %d", cmd)
+		return
+	}
+	if resp == nil {
+		// thiet bi offline: khong tra loi, driver se het thoi gian cho
+		return
+	}
+	resp.Seq = req.Seq
+	err := s.send(cmd, *resp)
+	if err != nil {
+		s.cfg.Logf("simulator: gui phan hoi loi: %v", err)
+		return
+	}
+	for _, a := range announce {
+		s.send(deviceAnnounceCmd, a)
+	}
+}
+
+func (s *Simulator) handleCommand(req message) *message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	resp := &message{
+		Address:     req.Address,
+		Type:        req.Type,
+		Endpoint:    req.Endpoint,
+		ProfileID:   req.ProfileID,
+		ClusterID:   req.ClusterID,
+		AttributeID: req.AttributeID,
+		ValueType:   req.ValueType,
+	}
+	ob, ok := s.objects[req.Address]
+	if !ok {
+		resp.Status = statusNotFound
+		return resp
+	}
+	if ob.typ != TypeDevice {
+		// group/scenario: coordinator tu xu ly, luon thanh cong
+		return resp
+	}
+	d := s.devices[ob.mac]
+	if d.Offline {
+		return nil
+	}
+
+	att := findAttribute(d, req.ClusterID, req.AttributeID)
+	switch req.CommandID {
+	case commandIDRead:
+		if att == nil {
+			resp.Status = statusUnsupportedAttribute
+			return resp
+		}
+		resp.ValueType = att.ValueType
+		resp.Value = att.Value
+	case commandIDWrite:
+		if att == nil {
+			resp.Status = statusUnsupportedAttribute
+			return resp
+		}
+		if att.ReadOnly {
+			resp.Status = statusReadOnly
+			return resp
+		}
+		att.Value = req.Value
+	case commandIDDelete:
+	default:
+		resp.Status = statusFailure
+	}
+	return resp
+}
+
+func (s *Simulator) handleAddObject(req message) *message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if req.MAC == groupMAC || req.MAC == "" {
+		addr := s.allocAddressWithoutSync()
+		s.objects[addr] = &object{typ: TypeGroup, name: req.Name, endpoint: 1}
+		return &message{
+			PAN:      s.cfg.PAN,
+			Address:  addr,
+			Type:     TypeGroup,
+			Endpoint: 1,
+			Name:     req.Name,
+		}
+	}
+
+	d, ok := s.devices[req.MAC]
+	if !ok {
+		return &message{MAC: req.MAC, PAN: req.PAN, Status: statusNotFound}
+	}
+	if d.Offline {
+		return nil
+	}
+	s.joinWithoutSync(d)
+	if req.Name != "" {
+		s.objects[d.Address].name = req.Name
+	}
+	msg := deviceMessage(d)
+	msg.Name = req.Name
+	return &msg
+}
+
+func (s *Simulator) handleDeleteObject(req message) *message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	resp := &message{Address: req.Address, Type: req.Type, Endpoint: req.Endpoint}
+	ob, ok := s.objects[req.Address]
+	if !ok {
+		resp.Status = statusNotFound
+		return resp
+	}
+	if ob.typ == TypeDevice {
+		d := s.devices[ob.mac]
+		if d.Offline && req.Leave {
+			// leave request khong toi duoc thiet bi
+			return nil
+		}
+		if req.Leave {
+			d.Joined = false
+			d.Address = 0
+		}
+	}
+	delete(s.objects, req.Address)
+	return resp
+}
+
+// handleScan : mo permit-join, cac thiet bi chua tham gia mang se tham gia va gui device-announce
+func (s *Simulator) handleScan(req message) (*message, []message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if req.ScanTime <= 0 {
+		return &message{Status: statusFailure}, nil
+	}
+	var announce []message
+	for _, d := range s.devices {
+		if d.Joined || d.Offline {
+			continue
+		}
+		s.joinWithoutSync(d)
+		announce = append(announce, deviceMessage(d))
+	}
+	return &message{}, announce
+}
+
+func (s *Simulator) joinWithoutSync(d *Device) {
+	d.Joined = true
+	if d.Address == 0 {
+		d.Address = s.allocAddressWithoutSync()
+	}
+	s.objects[d.Address] = &object{typ: TypeDevice, mac: d.MAC, name: d.Name, endpoint: d.Endpoint}
+}
+
+func (s *Simulator) allocAddressWithoutSync() uint16 {
+	for {
+		addr := s.nextAddr
+		s.nextAddr++
+		if s.nextAddr == 0 || s.nextAddr >= 0xFFF8 {
+			s.nextAddr = 1
+		}
+		if _, used := s.objects[addr]; !used {
+			return addr
+		}
+	}
+}
+
+func (s *Simulator) reportLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.ReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		s.mutex.Lock()
+		var reports []message
+		for _, d := range s.devices {
+			if !d.Joined || d.Offline {
+				continue
+			}
+			for _, att := range d.Attributes {
+				if att.Report {
+					reports = append(reports, pushMessage(d, att))
+				}
+			}
+		}
+		s.mutex.Unlock()
+
+		for _, r := range reports {
+			if err := s.send(pushEventCmd, r); err != nil {
+				s.cfg.Logf("simulator: gui PushEvent loi: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Simulator) send(cmd int8, msg message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	b, err := frame.Encode(frame.New(byte(cmd), payload))
+	if err != nil {
+		return err
+	}
+
+	s.wmutex.Lock()
+	defer s.wmutex.Unlock()
+	if s.w == nil {
+		return fmt.Errorf("chua co ket noi toi driver")
+	}
+	_, err = s.w.Write(b)
+	return err
+}
+
+func findAttribute(d *Device, clusterID uint16, attributeID uint16) *Attribute {
+	for i := range d.Attributes {
+		if d.Attributes[i].ClusterID == clusterID && d.Attributes[i].AttributeID == attributeID {
+			return &d.Attributes[i]
+		}
+	}
+	return nil
+}
+
+func deviceMessage(d *Device) message {
+	return message{
+		MAC:         d.MAC,
+		PAN:         d.PAN,
+		Address:     d.Address,
+		Type:        TypeDevice,
+		Endpoint:    d.Endpoint,
+		Name:        d.Name,
+		Description: d.Description,
+	}
+}
+
+func pushMessage(d *Device, att Attribute) message {
+	return message{
+		Address:     d.Address,
+		Type:        TypeDevice,
+		Endpoint:    d.Endpoint,
+		ProfileID:   att.ProfileID,
+		ClusterID:   att.ClusterID,
+		AttributeID: att.AttributeID,
+		ValueType:   att.ValueType,
+		Value:       att.Value,
+	}
+}